@@ -0,0 +1,197 @@
+package highlight
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// maxAggregationSamples bounds the samples kept per series to compute quantiles.
+// Beyond it, samples are kept by reservoir sampling.
+const maxAggregationSamples = 1024
+
+// maxAggregatedSeries bounds the series of a flush window. Values of new series beyond it
+// are dropped until the next flush.
+const maxAggregatedSeries = 10000
+
+// AggregationOptions configures client-side metric aggregation
+type AggregationOptions struct {
+	// Quantiles to export, e.g. 0.5, 0.9 and 0.99, as <name>.p50, <name>.p90 and <name>.p99.
+	Quantiles []float64
+	// Buckets are histogram upper bounds. For each bound, <name>.bucket is exported with an
+	// "le" tag holding the bound and the number of samples less than or equal to it.
+	Buckets []float64
+}
+
+// metricAggregator folds the metrics of a flush window into one series per
+// name, session, group, category and tags
+type metricAggregator struct {
+	opts AggregationOptions
+
+	mu     sync.Mutex
+	series map[string]*aggregatedSeries
+}
+
+type aggregatedSeries struct {
+	metric  MetricInput
+	count   int
+	sum     float64
+	min     float64
+	max     float64
+	samples []float64
+	buckets []int
+}
+
+var aggregator *metricAggregator
+
+// SetMetricAggregation enables aggregating metrics in process over each flush interval.
+// Instead of every recorded value, each series (metrics sharing a name, session, group,
+// category and tags) is exported as <name>.count, <name>.sum, <name>.min and <name>.max,
+// plus the quantiles and buckets set in opts. Spans are never aggregated, and aggregated
+// metrics lose their trace IDs and are not grouped by request ID; a Group set in
+// MetricOptions is kept. Past 10000 series in a flush interval, values of new series are
+// dropped. A nil opts disables aggregation. Call it before Start.
+func SetMetricAggregation(opts *AggregationOptions) {
+	if opts == nil {
+		aggregator = nil
+		return
+	}
+	buckets := append([]float64(nil), opts.Buckets...)
+	sort.Float64s(buckets)
+	aggregator = &metricAggregator{
+		opts:   AggregationOptions{Quantiles: opts.Quantiles, Buckets: buckets},
+		series: map[string]*aggregatedSeries{},
+	}
+}
+
+// add folds metric into its series, reporting false when it was dropped for the series limit
+func (a *metricAggregator) add(metric MetricInput) bool {
+	key := seriesKey(metric)
+	value := float64(metric.Value)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.series[key]
+	if !ok {
+		if len(a.series) >= maxAggregatedSeries {
+			return false
+		}
+		s = &aggregatedSeries{metric: metric, min: value, max: value, buckets: make([]int, len(a.opts.Buckets))}
+		a.series[key] = s
+	}
+	s.count++
+	s.sum += value
+	if value < s.min {
+		s.min = value
+	}
+	if value > s.max {
+		s.max = value
+	}
+	if len(a.opts.Quantiles) > 0 {
+		if len(s.samples) < maxAggregationSamples {
+			s.samples = append(s.samples, value)
+		} else if i := rand.Intn(s.count); i < maxAggregationSamples {
+			s.samples[i] = value
+		}
+	}
+	for i, bound := range a.opts.Buckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	return true
+}
+
+// drain returns the aggregates of the window and starts a new one. It is a no-op on a nil aggregator.
+func (a *metricAggregator) drain() []*MetricInput {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	series := a.series
+	a.series = map[string]*aggregatedSeries{}
+	a.mu.Unlock()
+
+	timestamp := time.Now().UTC()
+	var metrics []*MetricInput
+	for _, s := range series {
+		unit := s.metric.Unit
+		// counts and sums add up across windows, the other aggregates are levels
+		emit := func(suffix string, metricType MetricType, unit graphql.String, value float64, extra ...MetricTag) {
+			m := s.metric
+			m.Name = graphql.String(string(m.Name) + suffix)
+			m.Value = graphql.Float(value)
+			m.Type, m.Unit = graphql.String(metricType), unit
+			m.Timestamp = timestamp
+			m.TraceID, m.SpanID, m.ParentSpanID, m.RequestID = "", "", "", ""
+			if len(extra) > 0 {
+				m.Tags = append(append([]MetricTag(nil), m.Tags...), extra...)
+			}
+			metrics = append(metrics, &m)
+		}
+		emit(".count", MetricTypeCounter, "1", float64(s.count))
+		emit(".sum", MetricTypeCounter, unit, s.sum)
+		emit(".min", MetricTypeGauge, unit, s.min)
+		emit(".max", MetricTypeGauge, unit, s.max)
+		if len(s.samples) > 0 {
+			sort.Float64s(s.samples)
+			for _, q := range a.opts.Quantiles {
+				emit(".p"+quantileName(q), MetricTypeGauge, unit, quantile(s.samples, q))
+			}
+		}
+		for i, bound := range a.opts.Buckets {
+			emit(".bucket", MetricTypeCounter, "1", float64(s.buckets[i]), MetricTag{Name: "le", Value: graphql.String(strconv.FormatFloat(bound, 'f', -1, 64))})
+		}
+	}
+	return metrics
+}
+
+// seriesKey identifies the series of metric, independent of the order of its tags
+func seriesKey(metric MetricInput) string {
+	var b strings.Builder
+	b.WriteString(string(metric.Name))
+	b.WriteByte(0)
+	b.WriteString(string(metric.SessionSecureID))
+	b.WriteByte(0)
+	if metric.Group != nil {
+		b.WriteString(string(*metric.Group))
+	}
+	b.WriteByte(0)
+	if metric.Category != nil {
+		b.WriteString(string(*metric.Category))
+	}
+	tags := make([]string, 0, len(metric.Tags))
+	for _, tag := range metric.Tags {
+		tags = append(tags, string(tag.Name)+"="+string(tag.Value))
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		b.WriteByte(0)
+		b.WriteString(tag)
+	}
+	return b.String()
+}
+
+// quantile returns the q-quantile of sorted samples using the nearest rank method
+func quantile(sorted []float64, q float64) float64 {
+	if q <= 0 {
+		return sorted[0]
+	}
+	if q >= 1 {
+		return sorted[len(sorted)-1]
+	}
+	rank := int(q*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// quantileName formats 0.5 as 50 and 0.999 as 99_9
+func quantileName(q float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(q*100, 'f', -1, 64), ".", "_")
+}
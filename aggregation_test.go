@@ -0,0 +1,152 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestMetricAggregation(t *testing.T) {
+	requester = mockRequester{}
+	SetMetricAggregation(&AggregationOptions{Quantiles: []float64{0.5, 0.99}, Buckets: []float64{500, 100}})
+	defer SetMetricAggregation(nil)
+	Start()
+	defer Stop()
+	ctx := WithSession(context.Background(), "session", "request")
+
+	before := Stats()
+	histogram := NewHistogram("latency", nil)
+	for i := 1; i <= 1000; i++ {
+		histogram.Record(ctx, float64(i), Attr("region", "eu"), Attr("route", "/"))
+	}
+	// same series regardless of tag order
+	histogram.Record(ctx, 1, Attr("route", "/"), Attr("region", "eu"))
+	histogram.Record(ctx, 1, Attr("region", "us"))
+	_, span := StartSpan(ctx, "not aggregated")
+	span.End()
+	after := Stats()
+	if after.MetricsAggregated-before.MetricsAggregated != 1002 {
+		t.Errorf("wrong number of aggregated values [%v != %v]", after.MetricsAggregated-before.MetricsAggregated, 1002)
+	}
+
+	_, metrics := flush()
+	values := map[string]float64{}
+	for _, m := range metrics {
+		if len(m.Tags) > 0 && m.Tags[0].Value == "us" {
+			continue
+		}
+		name := string(m.Name)
+		for _, tag := range m.Tags {
+			if tag.Name == "le" {
+				name += "{le=" + string(tag.Value) + "}"
+			}
+		}
+		values[name] = float64(m.Value)
+	}
+	// count, sum, min, max, two quantiles and two buckets per series, plus the span
+	if len(metrics) != 2*8+1 {
+		t.Errorf("flush returned the wrong number of metrics [%v != %v]", len(metrics), 2*8+1)
+	}
+	expected := map[string]float64{
+		"latency.count":          1001,
+		"latency.sum":            500501,
+		"latency.min":            1,
+		"latency.max":            1000,
+		"latency.p50":            500,
+		"latency.p99":            990,
+		"latency.bucket{le=100}": 101,
+		"latency.bucket{le=500}": 501,
+		"not aggregated":         values["not aggregated"],
+	}
+	for name, value := range expected {
+		if v, ok := values[name]; !ok || v != value {
+			t.Errorf("wrong value for %s [%v != %v]", name, v, value)
+		}
+	}
+}
+
+func TestMetricAggregationAcrossRequests(t *testing.T) {
+	requester = mockRequester{}
+	SetMetricAggregation(&AggregationOptions{})
+	defer SetMetricAggregation(nil)
+	Start()
+	defer Stop()
+
+	histogram := NewHistogram("latency", nil)
+	for _, request := range []string{"a", "b", "c"} {
+		histogram.Record(WithSession(context.Background(), "session", request), 1)
+	}
+	NewHistogram("latency", &MetricOptions{Group: "workers"}).Record(WithSession(context.Background(), "session", "d"), 1)
+
+	_, metrics := flush()
+	counts := map[string]float64{}
+	for _, m := range metrics {
+		if m.Name != "latency.count" {
+			continue
+		}
+		group := ""
+		if m.Group != nil {
+			group = string(*m.Group)
+		}
+		counts[group] = float64(m.Value)
+	}
+	if len(counts) != 2 || counts[""] != 3 || counts["workers"] != 1 {
+		t.Errorf("values not aggregated across requests: %v", counts)
+	}
+}
+
+func TestMetricAggregationSeriesLimit(t *testing.T) {
+	requester = mockRequester{}
+	SetMetricAggregation(&AggregationOptions{})
+	defer SetMetricAggregation(nil)
+	Start()
+	defer Stop()
+	ctx := WithSession(context.Background(), "session", "request")
+
+	before := Stats()
+	for i := 0; i <= maxAggregatedSeries; i++ {
+		RecordMetric(ctx, fmt.Sprintf("metric.%d", i), 1)
+	}
+	after := Stats()
+	if dropped := after.Dropped[DropReasonSeriesLimit] - before.Dropped[DropReasonSeriesLimit]; dropped != 1 {
+		t.Errorf("wrong number of values dropped for the series limit [%v != %v]", dropped, 1)
+	}
+	if _, metrics := flush(); len(metrics) != 4*maxAggregatedSeries {
+		t.Errorf("flush returned the wrong number of metrics [%v != %v]", len(metrics), 4*maxAggregatedSeries)
+	}
+}
+
+func TestMetricAggregationKinds(t *testing.T) {
+	requester = mockRequester{}
+	SetMetricAggregation(&AggregationOptions{Quantiles: []float64{0.5}, Buckets: []float64{100}})
+	defer SetMetricAggregation(nil)
+	Start()
+	defer Stop()
+	ctx := WithSession(context.Background(), "session", "request")
+
+	flush()
+	NewHistogram("payload.size", &MetricOptions{Unit: "By"}).Record(ctx, 10)
+	_, metrics := flush()
+	// counts add up and have no unit, sums add up in the unit of the values, the rest are levels
+	expected := map[string][2]string{
+		"payload.size.count":  {"sum", "1"},
+		"payload.size.bucket": {"sum", "1"},
+		"payload.size.sum":    {"sum", "By"},
+		"payload.size.min":    {"gauge", "By"},
+		"payload.size.max":    {"gauge", "By"},
+		"payload.size.p50":    {"gauge", "By"},
+	}
+	if len(metrics) != len(expected) {
+		t.Errorf("flush returned the wrong number of metrics [%v != %v]", len(metrics), len(expected))
+	}
+	for _, m := range metrics {
+		exported := metricToOTLP(m)
+		kind := "gauge"
+		if exported.GetSum() != nil {
+			kind = "sum"
+		}
+		if e := expected[exported.Name]; kind != e[0] || exported.Unit != e[1] {
+			t.Errorf("%s exported as a %s in %q, expected a %s in %q", exported.Name, kind, exported.Unit, e[0], e[1])
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package highlight
+
+import (
+	"os"
+)
+
+var (
+	backendOnlyMode bool
+	serviceName     string
+	environment     string
+	hostname        string
+)
+
+func init() {
+	hostname, _ = os.Hostname()
+}
+
+// SetBackendOnlyMode allows errors and metrics to be recorded from contexts without
+// a highlight session, such as cron jobs, queue consumers, startup code or gRPC services.
+// In backend-only mode every error and metric is tagged with the service name, environment
+// and hostname, and those recorded without a session are marked as such in the payload
+// instead of being discarded.
+func SetBackendOnlyMode(enabled bool) {
+	backendOnlyMode = enabled
+}
+
+// SetServiceName sets the service name reported in backend-only mode.
+func SetServiceName(name string) {
+	serviceName = name
+}
+
+// SetEnvironment sets the environment (e.g. production, staging) reported in backend-only mode.
+func SetEnvironment(env string) {
+	environment = env
+}
+
+// SetHostname overrides the hostname reported in backend-only mode, which defaults to os.Hostname.
+func SetHostname(name string) {
+	hostname = name
+}
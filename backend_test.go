@@ -0,0 +1,39 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestBackendOnlyMode(t *testing.T) {
+	requester = mockRequester{}
+	SetBackendOnlyMode(true)
+	SetServiceName("worker")
+	SetEnvironment("test")
+	SetHostname("host-1")
+	defer SetBackendOnlyMode(false)
+
+	Start()
+	ConsumeError(context.Background(), fmt.Errorf("cron failed"))
+	RecordMetric(context.Background(), "jobs.processed", 3)
+	errs, metrics := flush()
+	if len(errs) != 1 || len(metrics) != 1 {
+		t.Fatalf("flush returned the wrong number of errors and metrics [%v, %v != 1, 1]", len(errs), len(metrics))
+	}
+	e := errs[0]
+	if !e.SessionAbsent || e.SessionSecureID != "" {
+		t.Errorf("error not marked as session-less: %+v", e)
+	}
+	if e.ServiceName != "worker" || e.Environment != "test" || e.Hostname != "host-1" {
+		t.Errorf("error not tagged with service details: %+v", e)
+	}
+	m := metrics[0]
+	if !m.SessionAbsent || m.Group != nil {
+		t.Errorf("metric not marked as session-less: %+v", m)
+	}
+	if m.ServiceName != "worker" || m.Environment != "test" || m.Hostname != "host-1" {
+		t.Errorf("metric not tagged with service details: %+v", m)
+	}
+	Stop()
+}
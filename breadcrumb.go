@@ -0,0 +1,132 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreadcrumbType is the kind of event a breadcrumb records
+type BreadcrumbType string
+
+const (
+	BreadcrumbLog  BreadcrumbType = "log"
+	BreadcrumbHTTP BreadcrumbType = "http"
+	BreadcrumbSQL  BreadcrumbType = "sql"
+)
+
+// Breadcrumb is an event that happened during a request before an error was consumed
+type Breadcrumb struct {
+	Type      BreadcrumbType    `json:"type"`
+	Level     string            `json:"level,omitempty"`
+	Message   string            `json:"message"`
+	Timestamp time.Time         `json:"timestamp"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+const breadcrumbsKey = Highlight + "Breadcrumbs"
+
+var breadcrumbLimit = 20
+
+// SetBreadcrumbLimit sets how many of the most recent breadcrumbs a request keeps
+// and attaches to its errors. The default is 20; 0 disables breadcrumbs.
+func SetBreadcrumbLimit(limit int) {
+	breadcrumbLimit = limit
+}
+
+// breadcrumbBuffer is a ring buffer of the most recent breadcrumbs of a request.
+// items is only allocated by the first add, as most requests never record one.
+type breadcrumbBuffer struct {
+	mu    sync.Mutex
+	limit int
+	items []Breadcrumb
+	next  int
+	full  bool
+}
+
+// WithBreadcrumbs returns a copy of ctx carrying a fresh breadcrumb buffer.
+// InterceptRequest does this for every request; call it yourself where requests
+// arrive some other way, alongside WithSession.
+func WithBreadcrumbs(ctx context.Context) context.Context {
+	if breadcrumbLimit <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, breadcrumbsKey, &breadcrumbBuffer{limit: breadcrumbLimit})
+}
+
+// AddBreadcrumb records b in the breadcrumb buffer carried by ctx, if any
+func AddBreadcrumb(ctx context.Context, b Breadcrumb) {
+	if b.Timestamp.IsZero() {
+		b.Timestamp = time.Now().UTC()
+	}
+	breadcrumbsFromContext(ctx).add(b)
+}
+
+// AddSQLBreadcrumb records a SQL statement and its outcome as a breadcrumb
+func AddSQLBreadcrumb(ctx context.Context, statement string, duration time.Duration, err error) {
+	b := Breadcrumb{
+		Type:    BreadcrumbSQL,
+		Message: statement,
+		Data:    map[string]string{"duration": duration.String()},
+	}
+	if err != nil {
+		b.Level = LevelError.String()
+		b.Data["error"] = err.Error()
+	}
+	AddBreadcrumb(ctx, b)
+}
+
+// httpBreadcrumb describes an outgoing request made through Transport
+func httpBreadcrumb(req *http.Request, resp *http.Response, err error, duration time.Duration) Breadcrumb {
+	b := Breadcrumb{
+		Type:      BreadcrumbHTTP,
+		Message:   fmt.Sprintf("%s %s", req.Method, scrubURL(req.URL)),
+		Timestamp: time.Now().UTC(),
+		Data:      map[string]string{"duration": duration.String()},
+	}
+	if resp != nil {
+		b.Data["status_code"] = fmt.Sprint(resp.StatusCode)
+	}
+	if err != nil {
+		b.Level = LevelError.String()
+		b.Data["error"] = err.Error()
+	}
+	return b
+}
+
+func breadcrumbsFromContext(ctx context.Context) *breadcrumbBuffer {
+	b, _ := ctx.Value(breadcrumbsKey).(*breadcrumbBuffer)
+	return b
+}
+
+// add overwrites the oldest breadcrumb once the buffer is full. It is a no-op on a nil buffer.
+func (b *breadcrumbBuffer) add(crumb Breadcrumb) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.items == nil {
+		b.items = make([]Breadcrumb, b.limit)
+	}
+	b.items[b.next] = crumb
+	b.next = (b.next + 1) % len(b.items)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// snapshot returns the buffered breadcrumbs, oldest first
+func (b *breadcrumbBuffer) snapshot() []Breadcrumb {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]Breadcrumb(nil), b.items[:b.next]...)
+	}
+	return append(append([]Breadcrumb(nil), b.items[b.next:]...), b.items[:b.next]...)
+}
@@ -0,0 +1,86 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBreadcrumbs(t *testing.T) {
+	requester = mockRequester{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	Start()
+	defer Stop()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Highlight-Request", "session/request")
+	ctx := InterceptRequest(r)
+
+	Log(ctx, LevelInfo, "charging card", Attr("amount", 42))
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error making request: %v", err)
+	}
+	resp.Body.Close()
+	AddSQLBreadcrumb(ctx, "SELECT 1", time.Millisecond, nil)
+	ConsumeError(ctx, fmt.Errorf("charge failed"))
+
+	errs, _ := flush()
+	logs := flushLogs()
+	if len(logs) != 1 || logs[0].Message != "charging card" || logs[0].RequestID != "request" {
+		t.Errorf("log not enqueued: %+v", logs)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("flush returned the wrong number of errors [%v != %v]", len(errs), 1)
+	}
+	crumbs := errs[0].Breadcrumbs
+	if len(crumbs) != 3 {
+		t.Fatalf("wrong number of breadcrumbs [%v != %v]", len(crumbs), 3)
+	}
+	for i, typ := range []BreadcrumbType{BreadcrumbLog, BreadcrumbHTTP, BreadcrumbSQL} {
+		if crumbs[i].Type != typ {
+			t.Errorf("breadcrumb %d has the wrong type [%v != %v]", i, crumbs[i].Type, typ)
+		}
+	}
+	if crumbs[0].Data["amount"] != "42" || crumbs[1].Data["status_code"] != "200" {
+		t.Errorf("breadcrumb data missing: %+v", crumbs)
+	}
+}
+
+func TestBreadcrumbBufferWraps(t *testing.T) {
+	SetBreadcrumbLimit(2)
+	defer SetBreadcrumbLimit(20)
+	ctx := WithBreadcrumbs(context.Background())
+	if breadcrumbsFromContext(ctx).items != nil {
+		t.Errorf("buffer allocated before the first breadcrumb")
+	}
+	for i := 0; i < 3; i++ {
+		AddBreadcrumb(ctx, Breadcrumb{Message: fmt.Sprint(i)})
+	}
+	crumbs := breadcrumbsFromContext(ctx).snapshot()
+	if len(crumbs) != 2 || crumbs[0].Message != "1" || crumbs[1].Message != "2" {
+		t.Errorf("buffer did not keep the latest breadcrumbs in order: %+v", crumbs)
+	}
+}
+
+func TestLogOverGraphQL(t *testing.T) {
+	requester = graphqlRequester{}
+	defer func() { requester = mockRequester{} }()
+	Start()
+	defer Stop()
+	ctx := WithBreadcrumbs(WithSession(context.Background(), "session", "request"))
+
+	Log(ctx, LevelInfo, "charging card")
+	if logs := flushLogs(); len(logs) != 0 {
+		t.Errorf("log line enqueued for the GraphQL API: %+v", logs)
+	}
+	if crumbs := breadcrumbsFromContext(ctx).snapshot(); len(crumbs) != 1 {
+		t.Errorf("log line not kept as a breadcrumb: %+v", crumbs)
+	}
+}
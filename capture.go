@@ -0,0 +1,250 @@
+package highlight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const captureKey = Highlight + "Capture"
+
+// redactedValue replaces redacted header values and JSON fields
+const redactedValue = "REDACTED"
+
+// CaptureOptions configures request and response capture
+type CaptureOptions struct {
+	// MaxBodyBytes caps how much of each body is kept. Defaults to 16 KiB.
+	MaxBodyBytes int
+	// RedactHeaders are headers whose values are replaced with REDACTED, in addition
+	// to Authorization, Cookie, Set-Cookie and Proxy-Authorization.
+	RedactHeaders []string
+	// RedactJSONFields are fields of JSON bodies, at any depth, whose values are replaced
+	// with REDACTED. Field names are matched case-insensitively.
+	RedactJSONFields []string
+}
+
+var defaultRedactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// captureConfig is CaptureOptions with defaults applied and names normalized for lookups
+type captureConfig struct {
+	maxBodyBytes     int
+	redactHeaders    map[string]bool
+	redactJSONFields map[string]bool
+}
+
+var captureSettings *captureConfig
+
+// SetRequestCapture enables buffering the headers and bodies of requests intercepted by the
+// middlewares, and of their responses, so they can be attached to errors consumed during the
+// request, including 5xx responses reported with SetReportServerErrors.
+// Bodies are only captured as the handler reads and writes them. A nil opts disables capture.
+func SetRequestCapture(opts *CaptureOptions) {
+	if opts == nil {
+		captureSettings = nil
+		return
+	}
+	c := &captureConfig{
+		maxBodyBytes:     opts.MaxBodyBytes,
+		redactHeaders:    map[string]bool{},
+		redactJSONFields: map[string]bool{},
+	}
+	if c.maxBodyBytes <= 0 {
+		c.maxBodyBytes = 16 << 10
+	}
+	for _, h := range append(append([]string(nil), defaultRedactedHeaders...), opts.RedactHeaders...) {
+		c.redactHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, f := range opts.RedactJSONFields {
+		c.redactJSONFields[strings.ToLower(f)] = true
+	}
+	captureSettings = c
+}
+
+// HTTPCapture is the redacted request, and response so far, attached to an error
+type HTTPCapture struct {
+	Method                string            `json:"method"`
+	URL                   string            `json:"url"`
+	RequestHeaders        map[string]string `json:"request_headers,omitempty"`
+	RequestBody           string            `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool              `json:"request_body_truncated,omitempty"`
+	StatusCode            int               `json:"status_code,omitempty"`
+	ResponseHeaders       map[string]string `json:"response_headers,omitempty"`
+	ResponseBody          string            `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool              `json:"response_body_truncated,omitempty"`
+}
+
+// requestCapture accumulates the request and response of a single request
+type requestCapture struct {
+	config *captureConfig
+
+	mu             sync.Mutex
+	method         string
+	url            string
+	requestHeader  http.Header
+	requestBody    cappedBuffer
+	statusCode     int
+	responseHeader http.Header
+	responseBody   cappedBuffer
+}
+
+// withCapture starts capturing r if capture is enabled, teeing its body as the handler reads it
+func withCapture(ctx context.Context, r *http.Request) context.Context {
+	config := captureSettings
+	if config == nil {
+		return ctx
+	}
+	c := &requestCapture{
+		config:        config,
+		method:        r.Method,
+		url:           scrubbedURL(r),
+		requestHeader: r.Header.Clone(),
+		requestBody:   cappedBuffer{max: config.maxBodyBytes},
+		responseBody:  cappedBuffer{max: config.maxBodyBytes},
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &teeBody{ReadCloser: r.Body, capture: c}
+	}
+	return context.WithValue(ctx, captureKey, c)
+}
+
+func captureFromContext(ctx context.Context) *requestCapture {
+	c, _ := ctx.Value(captureKey).(*requestCapture)
+	return c
+}
+
+func (c *requestCapture) writeRequestBody(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestBody.Write(b)
+}
+
+func (c *requestCapture) writeResponseHeader(status int, header http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.statusCode == 0 {
+		c.statusCode = status
+		c.responseHeader = header.Clone()
+	}
+}
+
+func (c *requestCapture) writeResponseBody(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responseBody.Write(b)
+}
+
+// snapshot returns the redacted capture so far. It is nil on a nil capture.
+func (c *requestCapture) snapshot() *HTTPCapture {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &HTTPCapture{
+		Method:                c.method,
+		URL:                   c.url,
+		RequestHeaders:        c.config.redactHeader(c.requestHeader),
+		RequestBody:           c.config.redactBody(c.requestHeader, &c.requestBody),
+		RequestBodyTruncated:  c.requestBody.truncated,
+		StatusCode:            c.statusCode,
+		ResponseHeaders:       c.config.redactHeader(c.responseHeader),
+		ResponseBody:          c.config.redactBody(c.responseHeader, &c.responseBody),
+		ResponseBodyTruncated: c.responseBody.truncated,
+	}
+}
+
+func (c *captureConfig) redactHeader(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if c.redactHeaders[http.CanonicalHeaderKey(name)] {
+			redacted[name] = redactedValue
+			continue
+		}
+		redacted[name] = strings.Join(values, ", ")
+	}
+	return redacted
+}
+
+// redactBody redacts the configured fields of a JSON body. A JSON body that cannot be parsed,
+// e.g. because it was truncated, is dropped when fields are to be redacted, since they could
+// not be found reliably.
+func (c *captureConfig) redactBody(header http.Header, body *cappedBuffer) string {
+	if body.Len() == 0 {
+		return ""
+	}
+	if len(c.redactJSONFields) == 0 || !isJSON(header) {
+		return body.String()
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body.Bytes(), &doc); err != nil {
+		return "[unparseable JSON body omitted]"
+	}
+	c.redactJSON(doc)
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return "[unparseable JSON body omitted]"
+	}
+	return string(redacted)
+}
+
+func (c *captureConfig) redactJSON(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if c.redactJSONFields[strings.ToLower(k)] {
+				v[k] = redactedValue
+				continue
+			}
+			c.redactJSON(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			c.redactJSON(child)
+		}
+	}
+}
+
+func isJSON(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// cappedBuffer keeps the first max bytes written to it
+type cappedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// teeBody copies what the handler reads from a request body into its capture
+type teeBody struct {
+	io.ReadCloser
+	capture *requestCapture
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.capture.writeRequestBody(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,96 @@
+package highlight
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestCapture(t *testing.T) {
+	requester = mockRequester{}
+	SetRequestCapture(&CaptureOptions{MaxBodyBytes: 64, RedactHeaders: []string{"X-Api-Key"}, RedactJSONFields: []string{"password"}})
+	defer SetRequestCapture(nil)
+	SetReportServerErrors(true)
+	defer SetReportServerErrors(false)
+	Start()
+	defer Stop()
+
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(`{"user":"ada","password":"hunter2"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-Api-Key", "secret")
+	r.Header.Set("X-Highlight-Request", "session/request")
+	ctx := InterceptRequest(r)
+	req := StartServerRequest(ctx, r, "/login")
+	w := req.WrapResponseWriter(httptest.NewRecorder())
+	_, _ = io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	req.End(w.Status(), w.BytesWritten())
+
+	errs, _ := flush()
+	if len(errs) != 1 || errs[0].Capture == nil {
+		t.Fatalf("5xx response not reported with a capture: %+v", errs)
+	}
+	c := errs[0].Capture
+	if c.RequestHeaders["Authorization"] != "REDACTED" || c.RequestHeaders["X-Api-Key"] != "REDACTED" {
+		t.Errorf("headers not redacted: %v", c.RequestHeaders)
+	}
+	if c.RequestBody != `{"password":"REDACTED","user":"ada"}` {
+		t.Errorf("JSON field not redacted: %v", c.RequestBody)
+	}
+	if c.StatusCode != http.StatusInternalServerError || len(c.ResponseBody) != 64 || !c.ResponseBodyTruncated {
+		t.Errorf("response not captured up to the cap: %+v", c)
+	}
+}
+
+func TestRequestCaptureDropsUnparseableJSON(t *testing.T) {
+	SetRequestCapture(&CaptureOptions{MaxBodyBytes: 10, RedactJSONFields: []string{"password"}})
+	defer SetRequestCapture(nil)
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(`{"password":"hunter2"}`))
+	r.Header.Set("Content-Type", "application/json")
+	ctx := InterceptRequest(r)
+	_, _ = io.ReadAll(r.Body)
+	if body := captureFromContext(ctx).snapshot().RequestBody; strings.Contains(body, "hunter2") {
+		t.Errorf("truncated JSON body leaked a redacted field: %v", body)
+	}
+}
+
+func TestRequestCaptureLeavesServerErrorsOff(t *testing.T) {
+	requester = mockRequester{}
+	SetRequestCapture(&CaptureOptions{})
+	defer SetRequestCapture(nil)
+	Start()
+	defer Stop()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Highlight-Request", "session/request")
+	ctx := InterceptRequest(r)
+	StartServerRequest(ctx, r, "/").End(http.StatusInternalServerError, 0)
+	ConsumeError(ctx, errors.New("charge failed"))
+
+	errs, _ := flush()
+	if len(errs) != 1 || errs[0].Capture == nil {
+		t.Fatalf("only the consumed error should be reported, with a capture: %+v", errs)
+	}
+	// the GraphQL API has no fields for captures, breadcrumbs or trace IDs, so they go in the payload
+	sent := withGraphQLPayloads(errs)[0]
+	body, _ := json.Marshal(sent)
+	for _, field := range []string{"capture", "breadcrumbs", "trace_id", "span_id"} {
+		if strings.Contains(string(body), `"`+field+`"`) {
+			t.Errorf("%s sent to the GraphQL API as a field: %s", field, body)
+		}
+	}
+	var payload graphqlPayload
+	if err := json.Unmarshal([]byte(*sent.Payload), &payload); err != nil || payload.Capture == nil || payload.Capture.Method != "GET" {
+		t.Errorf("capture not folded into the payload: %s (%v)", *sent.Payload, err)
+	}
+	if errs[0].Payload == sent.Payload {
+		t.Errorf("the queued error was modified")
+	}
+}
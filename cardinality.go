@@ -0,0 +1,90 @@
+package highlight
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// overflowTagValue replaces tag values beyond the cardinality limit
+const overflowTagValue = "other"
+
+// cardinalityGuard remembers the values seen for each metric name and tag key
+type cardinalityGuard struct {
+	mu     sync.Mutex
+	limit  int
+	values map[string]map[string]map[string]struct{}
+}
+
+var tagCardinality = &cardinalityGuard{limit: 100, values: map[string]map[string]map[string]struct{}{}}
+
+// SetTagCardinalityLimit sets how many distinct values a tag key may take per metric name.
+// Once the limit is reached, new values are recorded as "other", so an unbounded tag such as
+// a user ID cannot explode the number of series. Spans are not limited.
+// The default is 100; 0 disables the limit.
+func SetTagCardinalityLimit(limit int) {
+	tagCardinality.mu.Lock()
+	defer tagCardinality.mu.Unlock()
+	tagCardinality.limit = limit
+	tagCardinality.values = map[string]map[string]map[string]struct{}{}
+}
+
+// apply rewrites the tags of metric whose values are over the limit, returning the rewritten tags
+func (g *cardinalityGuard) apply(name string, tags []MetricTag) []MetricTag {
+	if len(tags) == 0 {
+		return tags
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.limit <= 0 {
+		return tags
+	}
+	keys, ok := g.values[name]
+	if !ok {
+		keys = map[string]map[string]struct{}{}
+		g.values[name] = keys
+	}
+	var rewritten []MetricTag
+	for i, tag := range tags {
+		seen, ok := keys[string(tag.Name)]
+		if !ok {
+			seen = map[string]struct{}{}
+			keys[string(tag.Name)] = seen
+		}
+		if _, ok := seen[string(tag.Value)]; ok {
+			continue
+		}
+		if len(seen) < g.limit {
+			seen[string(tag.Value)] = struct{}{}
+			continue
+		}
+		if rewritten == nil {
+			// the caller's slice may be shared, e.g. by an instrument's tags
+			rewritten = append([]MetricTag(nil), tags...)
+		}
+		rewritten[i].Value = overflowTagValue
+		logger.Debug("tag over cardinality limit, recording as other", "metric", name, "tag", tag.Name, "limit", g.limit)
+	}
+	if rewritten == nil {
+		return tags
+	}
+	return rewritten
+}
+
+// RecordMetricWithTags is RecordMetric with dimensions, so a value can be sliced by e.g.
+// endpoint or region without encoding them in the metric name:
+//
+//	highlight.RecordMetricWithTags(ctx, "checkout.latency", 0.25, highlight.Attr("region", "eu"))
+//
+// See SetTagCardinalityLimit for how tags with many distinct values are handled.
+func RecordMetricWithTags(ctx context.Context, name string, value float64, tags ...Attribute) {
+	metric := MetricInput{
+		Name:  graphql.String(name),
+		Value: graphql.Float(value),
+	}
+	for _, a := range tags {
+		metric.Tags = append(metric.Tags, MetricTag{Name: graphql.String(a.Key), Value: graphql.String(a.Value)})
+	}
+	recordMetric(ctx, metric)
+}
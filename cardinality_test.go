@@ -0,0 +1,49 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+func TestRecordMetricWithTags(t *testing.T) {
+	requester = mockRequester{}
+	SetTagCardinalityLimit(2)
+	defer SetTagCardinalityLimit(100)
+	Start()
+	defer Stop()
+	ctx := WithSession(context.Background(), "session", "request")
+
+	for i := 0; i < 4; i++ {
+		RecordMetricWithTags(ctx, "checkout.latency", 0.25, Attr("user", i), Attr("region", "eu"))
+	}
+	// the limit applies per metric name
+	RecordMetricWithTags(ctx, "signup.latency", 0.25, Attr("user", 3))
+
+	_, metrics := flush()
+	if len(metrics) != 5 {
+		t.Fatalf("flush returned the wrong number of metrics [%v != %v]", len(metrics), 5)
+	}
+	for i, expected := range []string{"0", "1", "other", "other", "3"} {
+		tags := metrics[i].Tags
+		if string(tags[0].Value) != expected {
+			t.Errorf("wrong user tag for metric %d [%v != %v]", i, tags[0].Value, expected)
+		}
+		if i < 4 && tags[1].Value != "eu" {
+			t.Errorf("region tag was collapsed: %v", tags)
+		}
+	}
+}
+
+func TestTagCardinalityLimitDisabled(t *testing.T) {
+	SetTagCardinalityLimit(0)
+	defer SetTagCardinalityLimit(100)
+	for i := 0; i < 200; i++ {
+		tags := tagCardinality.apply("m", []MetricTag{{Name: "id", Value: graphql.String(fmt.Sprint(i))}})
+		if tags[0].Value == overflowTagValue {
+			t.Fatalf("value %d collapsed with the limit disabled", i)
+		}
+	}
+}
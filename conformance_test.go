@@ -0,0 +1,30 @@
+package highlight_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/highlight-run/highlight-go"
+	"github.com/highlight-run/highlight-go/internal/conformance"
+)
+
+func TestHandlerConformance(t *testing.T) {
+	// the ServeMux patterns of go 1.19 have no wildcards, so parameters become subtrees
+	template := func(path string) string {
+		if i := strings.IndexByte(path, '{'); i >= 0 {
+			return path[:i]
+		}
+		return path
+	}
+	conformance.Run(t, conformance.Adapter{
+		Template: template,
+		Serve: func(routes []conformance.Route) http.Handler {
+			mux := http.NewServeMux()
+			for _, route := range routes {
+				mux.Handle(template(route.Path), conformance.HTTPHandler(route))
+			}
+			return highlight.Handler(mux)
+		},
+	})
+}
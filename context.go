@@ -0,0 +1,72 @@
+package highlight
+
+import (
+	"context"
+	"time"
+)
+
+// Session holds the highlight identifiers carried by a context
+type Session struct {
+	SecureID  string
+	RequestID string
+}
+
+// WithSession returns a copy of ctx carrying the given highlight session and request IDs.
+// Use it when the IDs arrive through something other than an *http.Request,
+// e.g. a message queue, a WebSocket frame or gRPC metadata.
+func WithSession(ctx context.Context, sessionSecureID string, requestID string) context.Context {
+	ctx = context.WithValue(ctx, ContextKeys.SessionSecureID, sessionSecureID)
+	ctx = context.WithValue(ctx, ContextKeys.RequestID, requestID)
+	return ctx
+}
+
+// SessionFromContext returns the highlight session and request IDs carried by ctx.
+// ok is false if ctx does not carry both IDs.
+func SessionFromContext(ctx context.Context) (session Session, ok bool) {
+	sessionSecureID, hasSession := ctx.Value(ContextKeys.SessionSecureID).(string)
+	requestID, hasRequest := ctx.Value(ContextKeys.RequestID).(string)
+	if !hasSession || !hasRequest {
+		return Session{}, false
+	}
+	return Session{SecureID: sessionSecureID, RequestID: requestID}, true
+}
+
+// DetachedContext returns a context that is never canceled and has no deadline, carrying the
+// session and request IDs, route and trace context of ctx. Use it to hand the IDs to async
+// work that outlives the request. The request's breadcrumbs, capture and active span are left
+// behind, so the async work cannot write into them once the request is done.
+func DetachedContext(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// detachedKeys are the values of the parent exposed by a detached context
+var detachedKeys = map[contextKey]bool{
+	ContextKeys.SessionSecureID: true,
+	ContextKeys.RequestID:       true,
+	requestInfoKey:              true,
+	traceContextKey:             true,
+}
+
+// detachedContext only exposes the detachedKeys values of its parent
+type detachedContext struct {
+	parent context.Context
+}
+
+func (d detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (d detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (d detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	if k, ok := key.(contextKey); ok && detachedKeys[k] {
+		return d.parent.Value(k)
+	}
+	return nil
+}
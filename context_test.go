@@ -0,0 +1,49 @@
+package highlight
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetachedContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithSession(context.Background(), "session", "request"))
+	ctx = context.WithValue(ctx, struct{}{}, "other")
+	detached := DetachedContext(ctx)
+	cancel()
+
+	if detached.Err() != nil {
+		t.Errorf("detached context was canceled with its parent")
+	}
+	session, ok := SessionFromContext(detached)
+	if !ok || session.SecureID != "session" || session.RequestID != "request" {
+		t.Errorf("detached context lost the highlight IDs: %+v", session)
+	}
+	if detached.Value(struct{}{}) != nil {
+		t.Errorf("detached context exposed a non-highlight value")
+	}
+	if _, ok := SessionFromContext(context.Background()); ok {
+		t.Errorf("empty context reported a session")
+	}
+}
+
+func TestDetachedContextLeavesRequestState(t *testing.T) {
+	SetRequestCapture(&CaptureOptions{})
+	defer SetRequestCapture(nil)
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("X-Highlight-Request", "session/request")
+	ctx := WithRoute(InterceptRequest(r), "/users/{id}")
+	ctx, span := StartSpan(ctx, "job")
+	defer span.End()
+	detached := DetachedContext(ctx)
+
+	if route, _ := RouteFromContext(detached); route != "/users/{id}" {
+		t.Errorf("detached context lost the route: %q", route)
+	}
+	if breadcrumbsFromContext(detached) != nil || captureFromContext(detached) != nil || SpanFromContext(detached) != nil {
+		t.Errorf("detached context exposed the request's breadcrumbs, capture or span")
+	}
+	if _, ok := SessionFromContext(detached); !ok {
+		t.Errorf("detached context lost the highlight IDs")
+	}
+}
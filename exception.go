@@ -0,0 +1,38 @@
+package highlight
+
+import (
+	"context"
+	"time"
+)
+
+// Exception is an error captured elsewhere, e.g. translated from another telemetry library,
+// whose stack trace and time of occurrence are already known. ConsumeError reports its
+// StackTrace and Timestamp as-is instead of capturing them.
+type Exception struct {
+	Type       string
+	Message    string
+	StackTrace string
+	Timestamp  time.Time
+}
+
+func (e *Exception) Error() string {
+	if e.Type == "" {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Type
+	}
+	return e.Type + ": " + e.Message
+}
+
+// ErrorHook is called by ConsumeError with every error before it is enqueued.
+// Hooks may modify the error, e.g. to add trace IDs from another tracing library.
+type ErrorHook func(ctx context.Context, e *BackendErrorObjectInput)
+
+var errorHooks []ErrorHook
+
+// OnError registers an ErrorHook. It is not safe to call concurrently with ConsumeError,
+// so register hooks during setup, before Start.
+func OnError(hook ErrorHook) {
+	errorHooks = append(errorHooks, hook)
+}
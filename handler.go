@@ -0,0 +1,40 @@
+package highlight
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Handler wraps a standard library handler, such as an *http.ServeMux, with the highlight
+// middleware. It injects the session and request IDs into the request context, reports
+// panics with ConsumePanic and responds 500 instead of dropping the connection, and records
+// the request like the router middlewares (see SetServerMetrics, SetReportServerErrors
+// and SetRequestCapture):
+//
+//	mux := http.NewServeMux()
+//	...
+//	http.ListenAndServe(":8080", highlight.Handler(mux))
+//
+// When next is an *http.ServeMux, requests are named after the pattern they match.
+func Handler(next http.Handler) http.Handler {
+	return HTTPMiddleware(next, func(r *http.Request) string {
+		return serveMuxPattern(next, r)
+	})
+}
+
+// serveMuxPattern returns the pattern r matches when h is an *http.ServeMux, without
+// the method and host parts of Go 1.22 patterns such as "GET example.com/users/{id}"
+func serveMuxPattern(h http.Handler, r *http.Request) string {
+	mux, ok := h.(*http.ServeMux)
+	if !ok {
+		return ""
+	}
+	_, pattern := mux.Handler(r)
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i+1:], " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
@@ -0,0 +1,23 @@
+package highlight
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeMuxPattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("example.com/static/", func(w http.ResponseWriter, r *http.Request) {})
+	for target, expected := range map[string]string{
+		"http://example.com/static/app.js": "/static/",
+		"http://example.com/nowhere":       "",
+	} {
+		if pattern := serveMuxPattern(mux, httptest.NewRequest("GET", target, nil)); pattern != expected {
+			t.Errorf("wrong pattern for %s [%q != %q]", target, pattern, expected)
+		}
+	}
+	if pattern := serveMuxPattern(http.NotFoundHandler(), httptest.NewRequest("GET", "/", nil)); pattern != "" {
+		t.Errorf("pattern resolved for a handler that is not a ServeMux: %q", pattern)
+	}
+}
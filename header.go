@@ -0,0 +1,101 @@
+package highlight
+
+import (
+	"net/http"
+	"strings"
+)
+
+// maxHighlightIDLength bounds the length of a session secure ID or request ID
+// accepted from a client.
+const maxHighlightIDLength = 128
+
+var (
+	requestHeaderNames = []string{"X-Highlight-Request"}
+	sessionCookieName  string
+	sessionQueryParam  string
+)
+
+// SetRequestHeaderNames overrides the request headers, checked in order, that carry
+// the highlight session and request IDs. The default is X-Highlight-Request.
+func SetRequestHeaderNames(names ...string) {
+	requestHeaderNames = names
+}
+
+// SetSessionCookieName enables reading the IDs from a cookie when no header is present.
+// The cookie value uses the same "<session secure ID>/<request ID>" format as the header.
+// An empty name disables the fallback.
+func SetSessionCookieName(name string) {
+	sessionCookieName = name
+}
+
+// SetSessionQueryParam enables reading the IDs from a query parameter when neither a
+// header nor a cookie is present. The value uses the same format as the header.
+// An empty name disables the fallback.
+func SetSessionQueryParam(name string) {
+	sessionQueryParam = name
+}
+
+// sessionFromRequest looks for the highlight IDs in the configured headers,
+// then the W3C baggage header, then the cookie and then the query parameter.
+// The first value present is parsed; a malformed value is rejected rather than falling through.
+func sessionFromRequest(r *http.Request) (Session, bool) {
+	value, source := "", ""
+	for _, name := range requestHeaderNames {
+		if v := r.Header.Get(name); v != "" {
+			value, source = v, name
+			break
+		}
+	}
+	if value == "" {
+		if session, ok := sessionFromBaggage(r); ok {
+			return session, true
+		}
+	}
+	if value == "" && sessionCookieName != "" {
+		if c, err := r.Cookie(sessionCookieName); err == nil && c.Value != "" {
+			value, source = c.Value, "cookie "+sessionCookieName
+		}
+	}
+	if value == "" && sessionQueryParam != "" && r.URL != nil {
+		if v := r.URL.Query().Get(sessionQueryParam); v != "" {
+			value, source = v, "query parameter "+sessionQueryParam
+		}
+	}
+	if value == "" {
+		return Session{}, false
+	}
+	session, ok := parseHighlightHeader(value)
+	if !ok {
+		logger.Debug("rejecting malformed highlight request value", "source", source, "length", len(value))
+	}
+	return session, ok
+}
+
+// parseHighlightHeader parses a "<session secure ID>/<request ID>" value.
+// Both IDs must be non-empty, at most maxHighlightIDLength long and only contain
+// ASCII letters, digits, '-' or '_'.
+func parseHighlightHeader(value string) (Session, bool) {
+	if len(value) > 2*maxHighlightIDLength+1 {
+		return Session{}, false
+	}
+	sessionSecureID, requestID, found := strings.Cut(value, "/")
+	if !found || !validHighlightID(sessionSecureID) || !validHighlightID(requestID) {
+		return Session{}, false
+	}
+	return Session{SecureID: sessionSecureID, RequestID: requestID}, true
+}
+
+func validHighlightID(id string) bool {
+	if id == "" || len(id) > maxHighlightIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package highlight
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseHighlightHeader(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		ok      bool
+		session Session
+	}{
+		"valid":              {value: "abc123/req-1_2", ok: true, session: Session{SecureID: "abc123", RequestID: "req-1_2"}},
+		"empty":              {value: ""},
+		"missing request id": {value: "abc123/"},
+		"missing session id": {value: "/req"},
+		"no separator":       {value: "abc123"},
+		"extra segment":      {value: "abc/req/extra"},
+		"invalid charset":    {value: "abc<script>/req"},
+		"too long":           {value: strings.Repeat("a", maxHighlightIDLength+1) + "/req"},
+		"max length":         {value: strings.Repeat("a", maxHighlightIDLength) + "/req", ok: true, session: Session{SecureID: strings.Repeat("a", maxHighlightIDLength), RequestID: "req"}},
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			session, ok := parseHighlightHeader(input.value)
+			if ok != input.ok || session != input.session {
+				t.Errorf("parseHighlightHeader(%q) = %+v, %v; expected %+v, %v", input.value, session, ok, input.session, input.ok)
+			}
+		})
+	}
+}
+
+func TestSessionFromRequestFallbacks(t *testing.T) {
+	SetRequestHeaderNames("X-Highlight-Request", "X-Alt-Highlight")
+	SetSessionCookieName("highlight")
+	SetSessionQueryParam("highlight")
+	defer func() {
+		SetRequestHeaderNames("X-Highlight-Request")
+		SetSessionCookieName("")
+		SetSessionQueryParam("")
+	}()
+
+	r := httptest.NewRequest("GET", "/?highlight=query/q", nil)
+	if session, _ := sessionFromRequest(r); session.SecureID != "query" {
+		t.Errorf("query parameter fallback not used: %+v", session)
+	}
+	r.Header.Set("Cookie", "highlight=cookie/c")
+	if session, _ := sessionFromRequest(r); session.SecureID != "cookie" {
+		t.Errorf("cookie fallback not preferred over the query parameter: %+v", session)
+	}
+	r.Header.Set("X-Alt-Highlight", "alt/a")
+	if session, _ := sessionFromRequest(r); session.SecureID != "alt" {
+		t.Errorf("alternative header not preferred over the cookie: %+v", session)
+	}
+	r.Header.Set("X-Highlight-Request", "bad value/a")
+	if _, ok := sessionFromRequest(r); ok {
+		t.Errorf("malformed header was accepted")
+	}
+}
+
+func FuzzParseHighlightHeader(f *testing.F) {
+	f.Add("abc123/req")
+	f.Add("/")
+	f.Add("a/b/c")
+	f.Add(strings.Repeat("a", maxHighlightIDLength) + "/" + strings.Repeat("b", maxHighlightIDLength))
+	f.Fuzz(func(t *testing.T, value string) {
+		session, ok := parseHighlightHeader(value)
+		if !ok {
+			if session != (Session{}) {
+				t.Errorf("rejected value %q returned a session %+v", value, session)
+			}
+			return
+		}
+		if session.SecureID+"/"+session.RequestID != value {
+			t.Errorf("accepted value %q does not round trip: %+v", value, session)
+		}
+		if !validHighlightID(session.SecureID) || !validHighlightID(session.RequestID) {
+			t.Errorf("accepted value %q contains an invalid ID: %+v", value, session)
+		}
+	})
+}
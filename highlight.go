@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -20,12 +19,16 @@ import (
 var (
 	errorChan            chan BackendErrorObjectInput
 	metricChan           chan MetricInput
+	logChan              chan LogInput
 	flushInterval        time.Duration
 	client               *graphql.Client
 	interruptChan        chan bool
+	workerDone           chan struct{}
 	signalChan           chan os.Signal
 	wg                   sync.WaitGroup
+	flushMutex           sync.Mutex
 	graphqlClientAddress string
+	exportRetries        int
 )
 
 // contextKey represents the keys that highlight may store in the users' context
@@ -70,6 +73,9 @@ const backendSetupCooldown = 15
 const messageBufferSize = 1 << 16
 const metricCategory = "BACKEND"
 
+// exportRetryBackoff is the base delay between export attempts; it grows linearly with each retry
+const exportRetryBackoff = 100 * time.Millisecond
+
 var (
 	lastBackendSetupTimestamp time.Time
 )
@@ -81,26 +87,17 @@ const (
 )
 
 // Logger is an interface that implements Log and Logf
+// it is accepted by SetDebugMode; see LeveledLogger for structured logging
 type Logger interface {
 	Error(v ...interface{})
 	Errorf(format string, v ...interface{})
 }
 
-// log is this packages logger
-var logger struct {
-	Logger
-}
-
-// noop default logger
-type deadLog struct{}
-
-func (d deadLog) Error(v ...interface{})                 {}
-func (d deadLog) Errorf(format string, v ...interface{}) {}
-
 // Requester is used for making graphql requests
-// in testing, a mock requester with an overwritten trigger function may be used
+// in testing, a mock requester with overwritten trigger functions may be used
 type Requester interface {
 	trigger([]*BackendErrorObjectInput, []*MetricInput) error
+	triggerLogs([]*LogInput) error
 }
 
 var (
@@ -110,6 +107,7 @@ var (
 type graphqlRequester struct{}
 
 func (g graphqlRequester) trigger(errorsInput []*BackendErrorObjectInput, metricsInputs []*MetricInput) error {
+	errorsInput = withGraphQLPayloads(errorsInput)
 	if len(errorsInput) > 0 && len(metricsInputs) > 0 {
 		var mutation struct {
 			PushBackendPayload string `graphql:"pushBackendPayload(errors: $errors)"`
@@ -145,6 +143,54 @@ func (g graphqlRequester) trigger(errorsInput []*BackendErrorObjectInput, metric
 	return nil
 }
 
+// graphqlPayload is the Payload of an error sent to the GraphQL API with a trace,
+// breadcrumbs or a capture. Without any, the Payload is the JSON array of tags alone.
+type graphqlPayload struct {
+	Tags        json.RawMessage `json:"tags,omitempty"`
+	TraceID     string          `json:"trace_id,omitempty"`
+	SpanID      string          `json:"span_id,omitempty"`
+	Breadcrumbs []Breadcrumb    `json:"breadcrumbs,omitempty"`
+	Capture     *HTTPCapture    `json:"capture,omitempty"`
+}
+
+// withGraphQLPayloads returns copies of errs with their trace IDs, breadcrumbs and
+// capture folded into their Payload, as the GraphQL API has no fields for them
+func withGraphQLPayloads(errs []*BackendErrorObjectInput) []*BackendErrorObjectInput {
+	converted := make([]*BackendErrorObjectInput, 0, len(errs))
+	for _, e := range errs {
+		if e.TraceID == "" && len(e.Breadcrumbs) == 0 && e.Capture == nil {
+			converted = append(converted, e)
+			continue
+		}
+		payload := graphqlPayload{
+			TraceID:     string(e.TraceID),
+			SpanID:      string(e.SpanID),
+			Breadcrumbs: e.Breadcrumbs,
+			Capture:     e.Capture,
+		}
+		if e.Payload != nil {
+			payload.Tags = json.RawMessage(*e.Payload)
+		}
+		b, err := json.Marshal(payload)
+		if err != nil {
+			logger.Error("error marshaling payload", "error", err)
+			converted = append(converted, e)
+			continue
+		}
+		c, payloadString := *e, graphql.String(b)
+		c.Payload = &payloadString
+		converted = append(converted, &c)
+	}
+	return converted
+}
+
+// triggerLogs drops log lines, which are only exported over OTLP. Log does not enqueue
+// them for this requester; lines queued before switching protocols end up here.
+func (g graphqlRequester) triggerLogs(logsInput []*LogInput) error {
+	_ = logsInput
+	return nil
+}
+
 type mockRequester struct{}
 
 func (m mockRequester) trigger(errorsInput []*BackendErrorObjectInput, metricsInput []*MetricInput) error {
@@ -154,6 +200,15 @@ func (m mockRequester) trigger(errorsInput []*BackendErrorObjectInput, metricsIn
 	return nil
 }
 
+func (m mockRequester) triggerLogs(logsInput []*LogInput) error {
+	// NOOP
+	_ = logsInput
+	return nil
+}
+
+// The JSON names of BackendErrorObjectInput and MetricInput are the fields of the GraphQL
+// API's inputs. Fields tagged `json:"-"` have no GraphQL field and are exported over OTLP;
+// over GraphQL, an error's trace IDs, breadcrumbs and capture are folded into its Payload.
 type BackendErrorObjectInput struct {
 	SessionSecureID graphql.String  `json:"session_secure_id"`
 	RequestID       graphql.String  `json:"request_id"`
@@ -164,8 +219,22 @@ type BackendErrorObjectInput struct {
 	StackTrace      graphql.String  `json:"stackTrace"`
 	Timestamp       time.Time       `json:"timestamp"`
 	Payload         *graphql.String `json:"payload"`
+	ServiceName     graphql.String  `json:"service_name,omitempty"`
+	Environment     graphql.String  `json:"environment,omitempty"`
+	Hostname        graphql.String  `json:"hostname,omitempty"`
+	SessionAbsent   graphql.Boolean `json:"session_absent,omitempty"`
+	// TraceID, SpanID, Breadcrumbs and Capture are exported over OTLP as attributes of
+	// the log record, and folded into Payload over GraphQL.
+	TraceID     graphql.String `json:"-"`
+	SpanID      graphql.String `json:"-"`
+	Breadcrumbs []Breadcrumb   `json:"-"`
+	Capture     *HTTPCapture   `json:"-"`
+	// ErrorType is the Go type of the consumed error, or the Type of an *Exception.
+	// It is only sent over OTLP, as the exception.type attribute.
+	ErrorType string `json:"-"`
 }
 
+// MetricInput is a metric value. See BackendErrorObjectInput for which fields are sent to the GraphQL API.
 type MetricInput struct {
 	SessionSecureID graphql.String  `json:"session_secure_id"`
 	Group           *graphql.String `json:"group"`
@@ -173,19 +242,40 @@ type MetricInput struct {
 	Value           graphql.Float   `json:"value"`
 	Category        *graphql.String `json:"category"`
 	Timestamp       time.Time       `json:"timestamp"`
+	ServiceName     graphql.String  `json:"service_name,omitempty"`
+	Environment     graphql.String  `json:"environment,omitempty"`
+	Hostname        graphql.String  `json:"hostname,omitempty"`
+	SessionAbsent   graphql.Boolean `json:"session_absent,omitempty"`
+	TraceID         graphql.String  `json:"trace_id,omitempty"`
+	SpanID          graphql.String  `json:"span_id,omitempty"`
+	ParentSpanID    graphql.String  `json:"parent_span_id,omitempty"`
+	Tags            []MetricTag     `json:"tags,omitempty"`
+	Type            graphql.String  `json:"type,omitempty"`
+	Unit            graphql.String  `json:"unit,omitempty"`
+	// RequestID is the request the metric was recorded in, which Group defaults to.
+	// It is only sent over OTLP, as the highlight.request_id attribute.
+	RequestID graphql.String `json:"-"`
+}
+
+// MetricTag is a key/value dimension attached to a metric
+type MetricTag struct {
+	Name  graphql.String `json:"name"`
+	Value graphql.String `json:"value"`
 }
 
 // init gets called once when you import the package
 func init() {
 	errorChan = make(chan BackendErrorObjectInput, messageBufferSize)
 	metricChan = make(chan MetricInput, messageBufferSize)
+	logChan = make(chan LogInput, messageBufferSize)
 	interruptChan = make(chan bool, 1)
 	signalChan = make(chan os.Signal, 1)
 
 	signal.Notify(signalChan, syscall.SIGABRT, syscall.SIGTERM, syscall.SIGINT)
 	SetGraphqlClientAddress("https://pub.highlight.run")
 	SetFlushInterval(2 * time.Second)
-	SetDebugMode(deadLog{})
+	SetExportRetries(2)
+	SetLogger(deadLog{})
 
 	requester = graphqlRequester{}
 }
@@ -213,15 +303,35 @@ func StartWithContext(ctx context.Context) {
 		}
 	}
 	client = graphql.NewClient(graphqlClientAddress, httpClient)
+	// drop an interrupt sent by a Stop that raced with the previous worker's shutdown
+	select {
+	case <-interruptChan:
+	default:
+	}
 	state = started
-	go func() {
+	windowStart = time.Now().UTC()
+	logger.Info("highlight worker started", "address", graphqlClientAddress, "flush_interval", flushInterval)
+	workerDone = make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		// runtime metrics are sampled on the worker; a nil channel never fires when they are disabled
+		var runtimeTicks <-chan time.Time
+		var collector *runtimeCollector
+		if runtimeMetricsInterval > 0 {
+			ticker := time.NewTicker(runtimeMetricsInterval)
+			defer ticker.Stop()
+			runtimeTicks = ticker.C
+			collector = newRuntimeCollector()
+		}
+		// a ticker, unlike time.After in the select, is not reset by runtime ticks
+		flushTicker := time.NewTicker(flushInterval)
+		defer flushTicker.Stop()
 		for {
 			select {
-			case <-time.After(flushInterval):
-				wg.Add(1)
-				flushedErrors, flushedMetrics := flush()
-				wg.Done()
-				_ = requester.trigger(flushedErrors, flushedMetrics)
+			case <-runtimeTicks:
+				collector.collect()
+			case <-flushTicker.C:
+				flushAndExport()
 			case <-interruptChan:
 				shutdown()
 				return
@@ -233,17 +343,52 @@ func StartWithContext(ctx context.Context) {
 				return
 			}
 		}
-	}()
+	}(workerDone)
 }
 
 // Stop sends an interrupt signal to the main process, closing the channels and returning the goroutines.
+// It returns once the worker has shut down.
 func Stop() {
 	stateMutex.RLock()
-	defer stateMutex.RUnlock()
 	if state == stopped || state == idle {
+		stateMutex.RUnlock()
 		return
 	}
-	interruptChan <- true
+	done := workerDone
+	// concurrent calls share one interrupt; a full channel means one is already pending
+	select {
+	case interruptChan <- true:
+	default:
+	}
+	stateMutex.RUnlock()
+	<-done
+}
+
+// Flush exports everything recorded so far without waiting for the next flush interval.
+// It blocks until the export is done, so it can be called right before the process exits,
+// e.g. by the logging integrations on fatal log lines. It does nothing unless the worker is started.
+func Flush() {
+	stateMutex.RLock()
+	running := state == started
+	stateMutex.RUnlock()
+	if running {
+		flushAndExport()
+	}
+}
+
+// flushAndExport drains the channels and exports them. Only one flush runs at a time,
+// since flush reads as many values as the channels held when it started.
+func flushAndExport() {
+	flushMutex.Lock()
+	defer flushMutex.Unlock()
+	wg.Add(1)
+	flushedErrors, flushedMetrics := flush()
+	wg.Done()
+	if len(flushedErrors) > 0 || len(flushedMetrics) > 0 {
+		logger.Debug("flushed batch", "errors", len(flushedErrors), "metrics", len(flushedMetrics))
+	}
+	export(flushedErrors, flushedMetrics)
+	exportLogs(flushLogs())
 }
 
 // SetFlushInterval allows you to override the amount of time in which the
@@ -259,8 +404,10 @@ func SetGraphqlClientAddress(newGraphqlClientAddress string) {
 	graphqlClientAddress = newGraphqlClientAddress
 }
 
-func SetDebugMode(l Logger) {
-	logger.Logger = l
+// SetExportRetries sets how many times a failed export is retried before
+// the batch is discarded. Retries happen on the worker goroutine.
+func SetExportRetries(retries int) {
+	exportRetries = retries
 }
 
 // InterceptRequest calls InterceptRequestWithContext using the request object's context
@@ -270,15 +417,24 @@ func InterceptRequest(r *http.Request) context.Context {
 
 // InterceptRequestWithContext captures the highlight session and request ID
 // for a particular request from the request headers, adding the values to the provided context.
+// Malformed values are ignored; see SetRequestHeaderNames, SetSessionCookieName
+// and SetSessionQueryParam for where the IDs are read from.
+// A W3C traceparent header is also captured so errors and metrics can be correlated with the trace,
+// a breadcrumb buffer is attached so errors carry what happened earlier in the request,
+// and the request's method, scrubbed URL and remote address are kept for ConsumeError (see RequestInfo).
+// With SetRequestCapture, the request body is also teed into a capture buffer as it is read.
 func InterceptRequestWithContext(ctx context.Context, r *http.Request) context.Context {
-	highlightReqDetails := r.Header.Get("X-Highlight-Request")
-	ids := strings.Split(highlightReqDetails, "/")
-	if len(ids) < 2 {
+	ctx = WithBreadcrumbs(ctx)
+	ctx = withRequestInfo(ctx, newRequestInfo(r))
+	ctx = withCapture(ctx, r)
+	if tc, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok {
+		ctx = WithTraceContext(ctx, tc)
+	}
+	session, ok := sessionFromRequest(r)
+	if !ok {
 		return ctx
 	}
-	ctx = context.WithValue(ctx, ContextKeys.SessionSecureID, ids[0])
-	ctx = context.WithValue(ctx, ContextKeys.RequestID, ids[1])
-	return ctx
+	return WithSession(ctx, session.SecureID, session.RequestID)
 }
 
 func MarkBackendSetup(ctx context.Context) {
@@ -296,7 +452,7 @@ func MarkBackendSetup(ctx context.Context) {
 
 			err := client.Mutate(context.Background(), &mutation, variables)
 			if err != nil {
-				logger.Errorf("[highlight-go] %v", errors.Wrap(err, "error marking backend setup"))
+				logger.Error("error marking backend setup", "error", err)
 				return
 			}
 		}
@@ -304,11 +460,13 @@ func MarkBackendSetup(ctx context.Context) {
 }
 
 // ConsumeError adds an error to the queue of errors to be sent to our backend.
-// the provided context must have the injected highlight keys from InterceptRequestWithContext.
+// the provided context must have the injected highlight keys from InterceptRequestWithContext,
+// unless backend-only mode is enabled with SetBackendOnlyMode.
 func ConsumeError(ctx context.Context, errorInput interface{}, tags ...string) {
 	sessionSecureID, requestID, err := validateRequest(ctx)
 	if err != nil {
-		logger.Errorf("[highlight-go] %v", err)
+		stats.drop(dropReasonForValidation(err))
+		logger.Warn("discarding error", "reason", err)
 		return
 	}
 
@@ -318,7 +476,8 @@ func ConsumeError(ctx context.Context, errorInput interface{}, tags ...string) {
 
 	tagsBytes, err := json.Marshal(tags)
 	if err != nil {
-		logger.Errorf("[highlight-go] %v", errors.Wrap(err, "error marshaling tags"))
+		stats.drop(DropReasonMarshalError)
+		logger.Error("error marshaling tags", "error", err)
 		return
 	}
 	tagsString := string(tagsBytes)
@@ -329,19 +488,40 @@ func ConsumeError(ctx context.Context, errorInput interface{}, tags ...string) {
 		Timestamp:       timestamp,
 		Payload:         (*graphql.String)(&tagsString),
 	}
+	if backendOnlyMode {
+		convertedError.ServiceName = graphql.String(serviceName)
+		convertedError.Environment = graphql.String(environment)
+		convertedError.Hostname = graphql.String(hostname)
+		convertedError.SessionAbsent = sessionSecureID == ""
+	}
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		convertedError.TraceID = graphql.String(tc.TraceID)
+		convertedError.SpanID = graphql.String(tc.SpanID)
+	}
 
 	switch e := errorInput.(type) {
+	case *Exception:
+		convertedError.ErrorType = e.Type
+		convertedError.Event = graphql.String(e.Error())
+		convertedError.StackTrace = graphql.String(e.Error())
+		if e.StackTrace != "" {
+			convertedError.StackTrace = graphql.String(e.StackTrace)
+		}
+		if !e.Timestamp.IsZero() {
+			convertedError.Timestamp = e.Timestamp.UTC()
+		}
 	case stackTracer:
+		convertedError.ErrorType = fmt.Sprintf("%T", e)
 		stack := e.StackTrace()
 		if len(stack) < 1 {
-			err := errors.New("no stack frames in stack trace for stackTracer errors")
-			logger.Errorf("[highlight-go] %v", err)
+			logger.Warn("no stack frames in stack trace for stackTracer errors")
 		}
 		var stackFrames []string
 		for _, frame := range stack {
 			frameBytes, err := frame.MarshalText()
 			if err != nil {
-				logger.Errorf("[highlight-go] %v", errors.Wrap(err, "error marshaling frame text"))
+				stats.drop(DropReasonMarshalError)
+				logger.Error("error marshaling frame text", "error", err)
 				return
 			}
 			stackFrames = append(stackFrames, string(frameBytes))
@@ -349,21 +529,39 @@ func ConsumeError(ctx context.Context, errorInput interface{}, tags ...string) {
 		convertedError.Event = graphql.String(fmt.Sprintf("%v", e.Error()))
 		stackFramesBytes, err := json.Marshal(stackFrames)
 		if err != nil {
-			logger.Errorf("[highlight-go] %v", errors.Wrap(err, "error marshaling stack frames"))
+			stats.drop(DropReasonMarshalError)
+			logger.Error("error marshaling stack frames", "error", err)
 			return
 		}
 		convertedError.StackTrace = graphql.String(stackFramesBytes)
 	case error:
+		convertedError.ErrorType = fmt.Sprintf("%T", e)
 		convertedError.Event = graphql.String(e.Error())
 		convertedError.StackTrace = graphql.String(e.Error())
 	default:
+		convertedError.ErrorType = fmt.Sprintf("%T", e)
 		convertedError.Event = graphql.String(fmt.Sprintf("%v", e))
 		convertedError.StackTrace = graphql.String(fmt.Sprintf("%v", e))
 	}
+	if info, ok := RequestInfoFromContext(ctx); ok {
+		convertedError.URL = graphql.String(info.URL)
+		convertedError.Source = graphql.String(info.source())
+	}
+	convertedError.Breadcrumbs = breadcrumbsFromContext(ctx).snapshot()
+	convertedError.Capture = captureFromContext(ctx).snapshot()
+	if span := SpanFromContext(ctx); span != nil {
+		span.markError(string(convertedError.Event))
+	}
+	for _, hook := range errorHooks {
+		hook(ctx, &convertedError)
+	}
 	select {
 	case errorChan <- convertedError:
+		stats.errorsEnqueued.Add(1)
+		logger.Debug("error enqueued", "session_secure_id", sessionSecureID, "request_id", requestID)
 	default:
-		logger.Errorf("[highlight-go] error channel full. discarding value for %s", sessionSecureID)
+		stats.drop(DropReasonChannelFull)
+		logger.Error("error channel full, discarding value", "session_secure_id", sessionSecureID)
 	}
 }
 
@@ -373,32 +571,93 @@ func ConsumeError(ctx context.Context, errorInput interface{}, tags ...string) {
 // as a metric that you would like to graph and monitor. You'll be able to view the metric
 // in the context of the session and network request and recorded it.
 func RecordMetric(ctx context.Context, name string, value float64) {
+	recordMetric(ctx, MetricInput{
+		Name:  graphql.String(name),
+		Value: graphql.Float(value),
+	})
+}
+
+// RecordMetricInput records a fully built metric, e.g. one translated from another
+// metrics library. Session, service and trace details it is missing are filled in from ctx.
+func RecordMetricInput(ctx context.Context, metric MetricInput) {
+	recordMetric(ctx, metric)
+}
+
+// recordMetric fills in the session, service and trace details of metric and enqueues it.
+// The group defaults to the request ID, unless the metric is aggregated across requests,
+// the category to BACKEND and the timestamp to now.
+func recordMetric(ctx context.Context, metric MetricInput) {
 	sessionSecureID, requestID, err := validateRequest(ctx)
 	if err != nil {
-		logger.Errorf("[highlight-go] %v", err)
+		stats.drop(dropReasonForValidation(err))
+		logger.Warn("discarding metric", "name", metric.Name, "reason", err)
 		return
 	}
 	// track invocation of this function to ensure shutdown waits
 	defer wg.Done()
 	wg.Add(1)
 
-	req := graphql.String(requestID)
-	cat := graphql.String(metricCategory)
-	metric := MetricInput{
-		SessionSecureID: graphql.String(sessionSecureID),
-		Group:           &req,
-		Name:            graphql.String(name),
-		Value:           graphql.Float(value),
-		Category:        &cat,
-		Timestamp:       time.Now().UTC(),
+	metric.SessionSecureID = graphql.String(sessionSecureID)
+	metric.RequestID = graphql.String(requestID)
+	if metric.Group == nil && (requestID != "" || !backendOnlyMode) && !aggregates(metric) {
+		req := graphql.String(requestID)
+		metric.Group = &req
+	}
+	if metric.Category == nil {
+		cat := graphql.String(metricCategory)
+		metric.Category = &cat
+	}
+	if metric.Timestamp.IsZero() {
+		metric.Timestamp = time.Now().UTC()
+	}
+	if backendOnlyMode {
+		metric.ServiceName = graphql.String(serviceName)
+		metric.Environment = graphql.String(environment)
+		metric.Hostname = graphql.String(hostname)
+		metric.SessionAbsent = sessionSecureID == ""
+	}
+	if tc, ok := TraceContextFromContext(ctx); ok && metric.TraceID == "" {
+		metric.TraceID = graphql.String(tc.TraceID)
+		metric.SpanID = graphql.String(tc.SpanID)
+	}
+	enqueueMetric(metric)
+}
+
+// enqueueMetric applies the tag cardinality limit to a filled in metric and
+// hands it to the aggregator, if enabled, or the metric channel
+func enqueueMetric(metric MetricInput) {
+	sessionSecureID := metric.SessionSecureID
+	if !isSpanMetric(metric) {
+		metric.Tags = tagCardinality.apply(string(metric.Name), metric.Tags)
+	}
+	if aggregates(metric) {
+		if !aggregator.add(metric) {
+			stats.drop(DropReasonSeriesLimit)
+			logger.Warn("aggregated series limit reached, discarding value", "name", metric.Name)
+			return
+		}
+		stats.metricsAggregated.Add(1)
+		return
 	}
 	select {
 	case metricChan <- metric:
+		stats.metricsEnqueued.Add(1)
+		logger.Debug("metric enqueued", "name", metric.Name, "session_secure_id", sessionSecureID)
 	default:
-		logger.Errorf("[highlight-go] metric channel full. discarding value for %s", sessionSecureID)
+		stats.drop(DropReasonChannelFull)
+		logger.Error("metric channel full, discarding value", "name", metric.Name, "session_secure_id", sessionSecureID)
 	}
 }
 
+func isSpanMetric(metric MetricInput) bool {
+	return metric.Category != nil && *metric.Category == spanCategory
+}
+
+// aggregates reports whether metric goes to the aggregator rather than the metric channel
+func aggregates(metric MetricInput) bool {
+	return aggregator != nil && !isSpanMetric(metric)
+}
+
 func validateRequest(ctx context.Context) (sessionSecureID string, requestID string, err error) {
 	stateMutex.RLock()
 	defer stateMutex.RUnlock()
@@ -408,13 +667,13 @@ func validateRequest(ctx context.Context) (sessionSecureID string, requestID str
 	}
 	if v := ctx.Value(ContextKeys.SessionSecureID); v != nil {
 		sessionSecureID = v.(string)
-	} else {
+	} else if !backendOnlyMode {
 		err = errors.New(consumeErrorSessionIDMissing)
 		return
 	}
 	if v := ctx.Value(ContextKeys.RequestID); v != nil {
 		requestID = v.(string)
-	} else {
+	} else if !backendOnlyMode {
 		err = errors.New(consumeErrorRequestIDMissing)
 		return
 	}
@@ -427,7 +686,13 @@ type stackTracer interface {
 	Error() string
 }
 
+// windowStart is when the current flush window began. flush moves it on, keeping the start
+// of the flushed window in flushedWindowStart, the start time of delta sums over OTLP.
+var windowStart, flushedWindowStart time.Time
+
 func flush() ([]*BackendErrorObjectInput, []*MetricInput) {
+	now := time.Now().UTC()
+	flushedWindowStart, windowStart = windowStart, now
 	tempChanSize := len(errorChan)
 	var flushedErrors []*BackendErrorObjectInput
 	for i := 0; i < tempChanSize; i++ {
@@ -440,9 +705,87 @@ func flush() ([]*BackendErrorObjectInput, []*MetricInput) {
 		e := <-metricChan
 		flushedMetrics = append(flushedMetrics, &e)
 	}
+	flushedMetrics = append(flushedMetrics, aggregator.drain()...)
 	return flushedErrors, flushedMetrics
 }
 
+// export sends a flushed batch through the requester, retrying failed attempts
+// and keeping the self-telemetry counters up to date.
+func export(flushedErrors []*BackendErrorObjectInput, flushedMetrics []*MetricInput) {
+	if len(flushedErrors) == 0 && len(flushedMetrics) == 0 {
+		return
+	}
+	start := time.Now()
+	// a requester that delivered part of the batch only retries the rest
+	pendingErrors, pendingMetrics := flushedErrors, flushedMetrics
+	err := withExportRetries(func() error {
+		err := requester.trigger(pendingErrors, pendingMetrics)
+		var partial *partialExportError
+		if errors.As(err, &partial) {
+			pendingErrors, pendingMetrics = partial.errors, partial.metrics
+		}
+		return err
+	})
+	if err != nil {
+		stats.exported(len(flushedErrors)-len(pendingErrors), len(flushedMetrics)-len(pendingMetrics), 0)
+		logger.Error("error exporting batch, discarding", "errors", len(pendingErrors), "metrics", len(pendingMetrics), "error", err)
+		return
+	}
+	stats.exported(len(flushedErrors), len(flushedMetrics), 0)
+	logger.Debug("exported batch", "errors", len(flushedErrors), "metrics", len(flushedMetrics), "latency", time.Since(start))
+}
+
+// partialExportError is returned by a requester that delivered only part of a batch,
+// with the errors and metrics that are left, so retrying does not send the rest twice
+type partialExportError struct {
+	err     error
+	errors  []*BackendErrorObjectInput
+	metrics []*MetricInput
+}
+
+func (e *partialExportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *partialExportError) Unwrap() error {
+	return e.err
+}
+
+// exportLogs is export for log lines, which are sent separately from errors and metrics
+func exportLogs(flushedLogs []*LogInput) {
+	if len(flushedLogs) == 0 {
+		return
+	}
+	start := time.Now()
+	err := withExportRetries(func() error {
+		return requester.triggerLogs(flushedLogs)
+	})
+	if err != nil {
+		logger.Error("error exporting logs, discarding", "logs", len(flushedLogs), "error", err)
+		return
+	}
+	stats.exported(0, 0, len(flushedLogs))
+	logger.Debug("exported logs", "logs", len(flushedLogs), "latency", time.Since(start))
+}
+
+// withExportRetries calls trigger until it succeeds or the retries run out,
+// returning the last error
+func withExportRetries(trigger func() error) error {
+	var err error
+	for attempt := 0; attempt <= exportRetries; attempt++ {
+		if attempt > 0 {
+			stats.exportRetries.Add(1)
+			logger.Warn("retrying export", "attempt", attempt, "error", err)
+			time.Sleep(time.Duration(attempt) * exportRetryBackoff)
+		}
+		if err = trigger(); err == nil {
+			return nil
+		}
+		stats.failed(err)
+	}
+	return err
+}
+
 func shutdown() {
 	stateMutex.Lock()
 	defer stateMutex.Unlock()
@@ -451,4 +794,5 @@ func shutdown() {
 	}
 	state = stopped
 	wg.Wait()
+	logger.Info("highlight worker stopped")
 }
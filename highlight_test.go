@@ -7,6 +7,7 @@ import (
 	"github.com/vektah/gqlparser/v2/ast"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -136,3 +137,61 @@ func TestTracer(t *testing.T) {
 	})
 	Stop()
 }
+
+func TestStopWaitsForWorker(t *testing.T) {
+	requester = mockRequester{}
+	for i := 0; i < 3; i++ {
+		Start()
+		done := workerDone
+		Stop()
+		select {
+		case <-done:
+		default:
+			t.Fatalf("Stop returned before the worker shut down")
+		}
+		stateMutex.RLock()
+		isStopped := state == stopped
+		stateMutex.RUnlock()
+		if !isStopped {
+			t.Fatalf("Stop returned before the client was stopped")
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	requester = mockRequester{}
+	SetFlushInterval(time.Hour)
+	defer SetFlushInterval(2 * time.Second)
+	Start()
+	defer Stop()
+	ctx := WithSession(context.Background(), "session", "request")
+
+	before := Stats()
+	ConsumeError(ctx, fmt.Errorf("fatal"))
+	Flush()
+	after := Stats()
+	if exported := after.ErrorsExported - before.ErrorsExported; exported != 1 {
+		t.Errorf("Flush returned before the error was exported [%v != %v]", exported, 1)
+	}
+	if after.QueueDepth != 0 {
+		t.Errorf("Flush left values queued: %v", after.QueueDepth)
+	}
+}
+
+func TestStaleInterrupt(t *testing.T) {
+	requester = mockRequester{}
+	Start()
+	Stop()
+	// what a Stop racing with the worker's shutdown leaves behind
+	interruptChan <- true
+
+	Start()
+	defer Stop()
+	time.Sleep(10 * time.Millisecond)
+	stateMutex.RLock()
+	running := state == started
+	stateMutex.RUnlock()
+	if !running {
+		t.Errorf("an interrupt left over from a previous Stop stopped the next worker")
+	}
+}
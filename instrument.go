@@ -0,0 +1,153 @@
+package highlight
+
+import (
+	"context"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// MetricType tells dashboards how the values of a metric combine
+type MetricType string
+
+const (
+	// MetricTypeCounter values are increments, summed into a rate or total
+	MetricTypeCounter MetricType = "counter"
+	// MetricTypeGauge values are the current level of something, e.g. a queue depth
+	MetricTypeGauge MetricType = "gauge"
+	// MetricTypeHistogram values are samples of a distribution, e.g. request sizes
+	MetricTypeHistogram MetricType = "histogram"
+	// MetricTypeTimer values are durations in seconds, e.g. latencies
+	MetricTypeTimer MetricType = "timer"
+)
+
+// MetricOptions configures an instrument. The zero value records metrics like RecordMetric:
+// category BACKEND, grouped by the request ID of the context.
+type MetricOptions struct {
+	// Unit of the recorded values, e.g. "By" or "ms". Timers always use "s".
+	Unit string
+	// Category overrides the BACKEND category.
+	Category string
+	// Group overrides grouping by request ID, e.g. to aggregate across requests.
+	Group string
+	// Tags are attached to every value, before the attributes of the individual call.
+	Tags []Attribute
+}
+
+// instrument holds what every typed instrument shares
+type instrument struct {
+	name       string
+	metricType MetricType
+	opts       MetricOptions
+}
+
+func newInstrument(name string, metricType MetricType, opts *MetricOptions) instrument {
+	i := instrument{name: name, metricType: metricType}
+	if opts != nil {
+		i.opts = *opts
+	}
+	if metricType == MetricTypeTimer {
+		i.opts.Unit = "s"
+	}
+	return i
+}
+
+func (i instrument) record(ctx context.Context, value float64, attrs []Attribute) {
+	metric := MetricInput{
+		Name:  graphql.String(i.name),
+		Value: graphql.Float(value),
+		Type:  graphql.String(i.metricType),
+		Unit:  graphql.String(i.opts.Unit),
+	}
+	if i.opts.Category != "" {
+		category := graphql.String(i.opts.Category)
+		metric.Category = &category
+	}
+	if i.opts.Group != "" {
+		group := graphql.String(i.opts.Group)
+		metric.Group = &group
+	}
+	for _, a := range i.opts.Tags {
+		metric.Tags = append(metric.Tags, MetricTag{Name: graphql.String(a.Key), Value: graphql.String(a.Value)})
+	}
+	for _, a := range attrs {
+		metric.Tags = append(metric.Tags, MetricTag{Name: graphql.String(a.Key), Value: graphql.String(a.Value)})
+	}
+	recordMetric(ctx, metric)
+}
+
+// Counter records increments of a running total, such as requests served or bytes sent
+type Counter struct {
+	instrument
+}
+
+// NewCounter returns a Counter named name. opts may be nil.
+func NewCounter(name string, opts *MetricOptions) *Counter {
+	return &Counter{newInstrument(name, MetricTypeCounter, opts)}
+}
+
+// Add records an increment of delta. Counters only go up, so negative deltas are
+// discarded; record values that go up and down with a Gauge.
+func (c *Counter) Add(ctx context.Context, delta float64, attrs ...Attribute) {
+	if delta < 0 {
+		logger.Warn("discarding negative counter increment", "name", c.name, "delta", delta)
+		return
+	}
+	c.record(ctx, delta, attrs)
+}
+
+// Gauge records the current value of something that goes up and down, such as a pool size
+type Gauge struct {
+	instrument
+}
+
+// NewGauge returns a Gauge named name. opts may be nil.
+func NewGauge(name string, opts *MetricOptions) *Gauge {
+	return &Gauge{newInstrument(name, MetricTypeGauge, opts)}
+}
+
+// Set records value as the current value
+func (g *Gauge) Set(ctx context.Context, value float64, attrs ...Attribute) {
+	g.record(ctx, value, attrs)
+}
+
+// Histogram records samples of a distribution, such as payload sizes
+type Histogram struct {
+	instrument
+}
+
+// NewHistogram returns a Histogram named name. opts may be nil.
+func NewHistogram(name string, opts *MetricOptions) *Histogram {
+	return &Histogram{newInstrument(name, MetricTypeHistogram, opts)}
+}
+
+// Record records a sample
+func (h *Histogram) Record(ctx context.Context, value float64, attrs ...Attribute) {
+	h.record(ctx, value, attrs)
+}
+
+// Timer records durations in seconds, such as latencies
+type Timer struct {
+	instrument
+}
+
+// NewTimer returns a Timer named name. opts may be nil; the unit is always seconds.
+func NewTimer(name string, opts *MetricOptions) *Timer {
+	return &Timer{newInstrument(name, MetricTypeTimer, opts)}
+}
+
+// Record records a duration
+func (t *Timer) Record(ctx context.Context, d time.Duration, attrs ...Attribute) {
+	t.record(ctx, d.Seconds(), attrs)
+}
+
+// Time calls fn and records how long it took:
+//
+//	highlight.NewTimer("db.query", nil).Time(ctx, func() { rows, err = db.Query(q) })
+func (t *Timer) Time(ctx context.Context, fn func(), attrs ...Attribute) {
+	start := time.Now()
+	defer func() {
+		t.Record(ctx, time.Since(start), attrs...)
+	}()
+	fn()
+}
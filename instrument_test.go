@@ -0,0 +1,53 @@
+package highlight
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInstruments(t *testing.T) {
+	requester = mockRequester{}
+	Start()
+	defer Stop()
+	ctx := WithSession(context.Background(), "session", "request")
+
+	opts := &MetricOptions{Unit: "By", Category: "QUEUE", Group: "workers", Tags: []Attribute{Attr("queue", "emails")}}
+	NewCounter("jobs.processed", opts).Add(ctx, 1, Attr("status", "ok"))
+	NewGauge("jobs.pending", nil).Set(ctx, 12)
+	NewHistogram("jobs.size", opts).Record(ctx, 2048)
+	NewTimer("jobs.duration", &MetricOptions{Unit: "ms"}).Time(ctx, func() { time.Sleep(time.Millisecond) })
+
+	_, metrics := flush()
+	if len(metrics) != 4 {
+		t.Fatalf("flush returned the wrong number of metrics [%v != %v]", len(metrics), 4)
+	}
+	counter := metrics[0]
+	if counter.Type != "counter" || counter.Unit != "By" || *counter.Category != "QUEUE" || *counter.Group != "workers" {
+		t.Errorf("counter options not applied: %+v", counter)
+	}
+	if len(counter.Tags) != 2 || counter.Tags[0].Name != "queue" || counter.Tags[1].Name != "status" {
+		t.Errorf("wrong counter tags: %+v", counter.Tags)
+	}
+	if gauge := metrics[1]; gauge.Type != "gauge" || *gauge.Category != metricCategory || *gauge.Group != "request" {
+		t.Errorf("gauge did not default to the BACKEND category and request group: %+v", gauge)
+	}
+	if timer := metrics[3]; timer.Type != "timer" || timer.Unit != "s" || timer.Value < 0.001 {
+		t.Errorf("timer did not record seconds: %+v", timer)
+	}
+}
+
+func TestCounterRejectsNegativeDeltas(t *testing.T) {
+	requester = mockRequester{}
+	Start()
+	defer Stop()
+	ctx := WithSession(context.Background(), "session", "request")
+
+	counter := NewCounter("jobs.processed", nil)
+	counter.Add(ctx, -1)
+	counter.Add(ctx, 2)
+	_, metrics := flush()
+	if len(metrics) != 1 || metrics[0].Value != 2 {
+		t.Errorf("negative increment recorded: %+v", metrics)
+	}
+}
@@ -0,0 +1,298 @@
+// Package conformance is the test suite every highlight middleware must pass. Each middleware
+// package describes how to mount routes behind it with an Adapter and calls Run from its tests.
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// Route is a route the suite serves through the middleware under test
+type Route struct {
+	Method string
+	// Path is the route template in chi syntax, e.g. /users/{id}
+	Path string
+	// Handle is the framework-agnostic handler. ctx is the context the middleware hands
+	// handlers, e.g. c.Request.Context() for gin or c.UserContext() for fiber.
+	Handle func(ctx context.Context, body []byte) (status int, response string)
+}
+
+// Adapter mounts routes behind a middleware
+type Adapter struct {
+	// Template converts a route template in chi syntax into the router's, e.g. Colon.
+	// nil keeps templates as they are.
+	Template func(path string) string
+	// Serve returns a handler serving routes through the middleware. Each route handler must
+	// call Route.Handle with the middleware's context and the request body, then write the
+	// returned status and response.
+	Serve func(routes []Route) http.Handler
+	// LateRoute is set for routers that only resolve the route once the handler chain has run,
+	// so handlers cannot see it in their context and requests are not counted in flight.
+	LateRoute bool
+	// ErrorHandlerCalls returns how many times the framework's error handler has run, for
+	// frameworks with one. Each failing request must reach it exactly once.
+	ErrorHandlerCalls func() int
+}
+
+// Colon converts a route template in chi syntax into the colon syntax of gin, echo and
+// fiber, e.g. /users/{id} into /users/:id
+func Colon(path string) string {
+	return paramPattern.ReplaceAllString(path, ":$1")
+}
+
+var paramPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// HTTPHandler is the handler of route for net/http compatible routers
+func HTTPHandler(route Route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		status, response := route.Handle(r.Context(), body)
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, response)
+	})
+}
+
+// recorder collects what the middleware reports during the suite
+type recorder struct {
+	mu       sync.Mutex
+	errors   []*highlight.BackendErrorObjectInput
+	metrics  []highlight.MetricInput
+	sessions []string
+}
+
+func (r *recorder) reset() []*highlight.BackendErrorObjectInput {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := r.errors
+	r.errors = nil
+	return errs
+}
+
+// flushMetrics exports the recorded metrics and returns the values of each metric by name
+func (r *recorder) flushMetrics() map[string][]highlight.MetricInput {
+	highlight.Flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byName := map[string][]highlight.MetricInput{}
+	for _, m := range r.metrics {
+		byName[string(m.Name)] = append(byName[string(m.Name)], m)
+	}
+	r.metrics = nil
+	return byName
+}
+
+// panicking is the handler of /panic, named so the stack trace can be checked
+func panicking(ctx context.Context, body []byte) (int, string) {
+	panic("boom")
+}
+
+// Run checks that the middleware injects the highlight IDs and route into the handler's
+// context, marks the backend as set up with the request's session, recovers and reports
+// panics, and records metrics, 5xx responses and captures.
+func Run(t *testing.T, a Adapter) {
+	rec := &recorder{}
+	highlight.OnError(func(ctx context.Context, e *highlight.BackendErrorObjectInput) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.errors = append(rec.errors, e)
+	})
+	// stands in for the GraphQL API, which MarkBackendSetup calls and metrics are exported to
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var request struct {
+			Variables struct {
+				Metrics []highlight.MetricInput `json:"metrics"`
+			} `json:"variables"`
+		}
+		_ = json.Unmarshal(body, &request)
+		rec.mu.Lock()
+		if strings.Contains(string(body), "markBackendSetup") {
+			rec.sessions = append(rec.sessions, string(body))
+		}
+		rec.metrics = append(rec.metrics, request.Variables.Metrics...)
+		rec.mu.Unlock()
+		_, _ = w.Write([]byte(`{"data":{}}`))
+	}))
+	defer backend.Close()
+	highlight.SetGraphqlClientAddress(backend.URL)
+	highlight.SetExportProtocol(highlight.ProtocolGraphQL)
+	highlight.Start()
+	defer highlight.Stop()
+
+	template := a.Template
+	if template == nil {
+		template = func(path string) string { return path }
+	}
+	var session highlight.Session
+	var route string
+	routes := []Route{
+		{Method: "GET", Path: "/users/{id}", Handle: func(ctx context.Context, body []byte) (int, string) {
+			session, _ = highlight.SessionFromContext(ctx)
+			route, _ = highlight.RouteFromContext(ctx)
+			highlight.ConsumeError(ctx, errors.New("lookup failed"))
+			return http.StatusOK, "ok"
+		}},
+		{Method: "GET", Path: "/panic", Handle: panicking},
+		{Method: "POST", Path: "/charges/{id}", Handle: func(ctx context.Context, body []byte) (int, string) {
+			return http.StatusServiceUnavailable, "card declined"
+		}},
+	}
+	h := a.Serve(routes)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("X-Highlight-Request", "session/request")
+		r.Header.Set("Authorization", "Bearer secret")
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("context", func(t *testing.T) {
+		rec.reset()
+		do("GET", "/users/123", "")
+		if session.SecureID != "session" || session.RequestID != "request" {
+			t.Errorf("IDs not injected into the handler's context: %+v", session)
+		}
+		users := template("/users/{id}")
+		if !a.LateRoute && route != users {
+			t.Errorf("wrong route in the handler's context [%q != %q]", route, users)
+		}
+		errs := rec.reset()
+		if len(errs) != 1 {
+			t.Fatalf("wrong number of reported errors [%v != %v]", len(errs), 1)
+		}
+		e := errs[0]
+		if e.SessionSecureID != "session" || e.RequestID != "request" || e.URL != "http://example.com/users/123" {
+			t.Errorf("error not tied to the request: %+v", e)
+		}
+		if !a.LateRoute && string(e.Source) != "GET "+users {
+			t.Errorf("wrong error source [%q != %q]", e.Source, "GET "+users)
+		}
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		for _, body := range rec.sessions {
+			if !strings.Contains(body, `"session_secure_id":"session"`) {
+				t.Errorf("backend marked as set up for the wrong session: %s", body)
+			}
+		}
+	})
+
+	t.Run("recovery", func(t *testing.T) {
+		rec.reset()
+		w := do("GET", "/panic", "")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("wrong status after a panic [%v != %v]", w.Code, http.StatusInternalServerError)
+		}
+		errs := rec.reset()
+		if len(errs) != 1 {
+			t.Fatalf("wrong number of reported errors [%v != %v]", len(errs), 1)
+		}
+		if errs[0].Event != "panic: boom" || errs[0].SessionSecureID != "session" {
+			t.Errorf("wrong panic reported: %+v", errs[0])
+		}
+		if !strings.Contains(string(errs[0].StackTrace), "conformance.panicking") {
+			t.Errorf("stack trace does not point at the handler: %s", errs[0].StackTrace)
+		}
+		if panics := "GET " + template("/panic"); !a.LateRoute && string(errs[0].Source) != panics {
+			t.Errorf("wrong panic source [%q != %q]", errs[0].Source, panics)
+		}
+	})
+
+	t.Run("error handler", func(t *testing.T) {
+		if a.ErrorHandlerCalls == nil {
+			t.Skip("the framework has no error handler")
+		}
+		before := a.ErrorHandlerCalls()
+		w := do("GET", "/panic", "")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("wrong status [%v != %v]", w.Code, http.StatusInternalServerError)
+		}
+		if calls := a.ErrorHandlerCalls() - before; calls != 1 {
+			t.Errorf("error handler ran %v times", calls)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		highlight.SetServerMetrics(true)
+		defer highlight.SetServerMetrics(false)
+		rec.flushMetrics()
+		do("GET", "/users/123", "")
+		do("GET", "/panic", "")
+		metrics := rec.flushMetrics()
+
+		// late routes name every metric after the resolved route, except .inflight, which is left out
+		users, panics := "http.server.GET "+template("/users/{id}"), "http.server.GET "+template("/panic")
+		for name, status := range map[string]string{users: "200", panics: "500"} {
+			counts := metrics[name+".statusCode"]
+			if len(counts) != 1 || counts[0].Value != 1 || counts[0].Type != "counter" || len(counts[0].Tags) != 1 ||
+				counts[0].Tags[0].Name != "status_code" || string(counts[0].Tags[0].Value) != status {
+				t.Errorf("%s.statusCode not counted with status_code %s: %+v", name, status, counts)
+			}
+			if len(metrics[name+".duration"]) != 1 || len(metrics[name+".requestBytes"]) != 1 {
+				t.Errorf("%s.duration or %s.requestBytes not recorded", name, name)
+			}
+		}
+		if sizes := metrics[users+".responseBytes"]; len(sizes) != 1 || sizes[0].Value != 2 {
+			t.Errorf("wrong response size recorded: %+v", sizes)
+		}
+		inflight := metrics[users+".inflight"]
+		if a.LateRoute {
+			for name := range metrics {
+				if strings.HasSuffix(name, ".inflight") {
+					t.Errorf("request with a late route counted in flight as %s", name)
+				}
+			}
+		} else if len(inflight) != 2 || inflight[0].Value != 1 || inflight[1].Value != 0 {
+			t.Errorf("in-flight count not recorded at start and end: %+v", inflight)
+		}
+	})
+
+	t.Run("server errors", func(t *testing.T) {
+		highlight.SetReportServerErrors(true)
+		defer highlight.SetReportServerErrors(false)
+		rec.reset()
+		w := do("POST", "/charges/7", "")
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("wrong status [%v != %v]", w.Code, http.StatusServiceUnavailable)
+		}
+		errs := rec.reset()
+		charges := "POST " + template("/charges/{id}")
+		if len(errs) != 1 || string(errs[0].Event) != charges+" returned 503 Service Unavailable" || string(errs[0].Source) != charges {
+			t.Errorf("5xx response not reported under its route: %+v", errs)
+		}
+	})
+
+	t.Run("capture", func(t *testing.T) {
+		highlight.SetRequestCapture(&highlight.CaptureOptions{RedactJSONFields: []string{"card"}})
+		defer highlight.SetRequestCapture(nil)
+		highlight.SetReportServerErrors(true)
+		defer highlight.SetReportServerErrors(false)
+		rec.reset()
+		do("POST", "/charges/7", `{"card":"4242","amount":42}`)
+		errs := rec.reset()
+		if len(errs) != 1 || errs[0].Capture == nil {
+			t.Fatalf("5xx response not reported with a capture: %+v", errs)
+		}
+		c := errs[0].Capture
+		if c.RequestHeaders["Authorization"] != "REDACTED" {
+			t.Errorf("Authorization header not redacted: %v", c.RequestHeaders)
+		}
+		if !strings.Contains(c.RequestBody, `"amount":42`) || strings.Contains(c.RequestBody, "4242") {
+			t.Errorf("wrong request body captured: %s", c.RequestBody)
+		}
+		if c.StatusCode != http.StatusServiceUnavailable || c.ResponseBody != "card declined" {
+			t.Errorf("wrong response captured: %v %q", c.StatusCode, c.ResponseBody)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package highlight
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// LogLevel is the severity of a log line, recorded with Log or logged internally by the SDK
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// LeveledLogger is a structured logger used for the SDK's internal logging.
+// keysAndValues are alternating key/value pairs, e.g. "errors", 3, "metrics", 10.
+// A *slog.Logger satisfies this interface directly.
+type LeveledLogger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// logger is this packages logger
+var logger LeveledLogger
+
+// noop default logger
+type deadLog struct{}
+
+func (d deadLog) Debug(msg string, keysAndValues ...interface{}) {}
+func (d deadLog) Info(msg string, keysAndValues ...interface{})  {}
+func (d deadLog) Warn(msg string, keysAndValues ...interface{})  {}
+func (d deadLog) Error(msg string, keysAndValues ...interface{}) {}
+
+// SetLogger sets the leveled logger used for the SDK's internal logging.
+// Every stage of the pipeline (enqueue, flush, export) logs through it.
+func SetLogger(l LeveledLogger) {
+	if l == nil {
+		l = deadLog{}
+	}
+	logger = l
+}
+
+// SetDebugMode sets a Logger that receives the SDK's internal log lines, each formatted as a
+// single line. Error lines go through its Error method. Debug, info and warning lines go
+// through Debugf, Infof and Warnf, or Printf, when the logger has them, and are dropped
+// otherwise rather than logged as errors.
+func SetDebugMode(l Logger) {
+	if l == nil {
+		SetLogger(nil)
+		return
+	}
+	SetLogger(debugModeLogger{l: l})
+}
+
+// debugModeLogger routes each level to the matching method of a Logger, when it has one
+type debugModeLogger struct {
+	l Logger
+}
+
+type printfer interface {
+	Printf(format string, v ...interface{})
+}
+
+func (d debugModeLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if l, ok := d.l.(interface {
+		Debugf(format string, v ...interface{})
+	}); ok {
+		l.Debugf("%s", formatLine(LevelDebug, msg, keysAndValues))
+		return
+	}
+	d.print(LevelDebug, msg, keysAndValues)
+}
+
+func (d debugModeLogger) Info(msg string, keysAndValues ...interface{}) {
+	if l, ok := d.l.(interface {
+		Infof(format string, v ...interface{})
+	}); ok {
+		l.Infof("%s", formatLine(LevelInfo, msg, keysAndValues))
+		return
+	}
+	d.print(LevelInfo, msg, keysAndValues)
+}
+
+func (d debugModeLogger) Warn(msg string, keysAndValues ...interface{}) {
+	if l, ok := d.l.(interface {
+		Warnf(format string, v ...interface{})
+	}); ok {
+		l.Warnf("%s", formatLine(LevelWarn, msg, keysAndValues))
+		return
+	}
+	d.print(LevelWarn, msg, keysAndValues)
+}
+
+func (d debugModeLogger) Error(msg string, keysAndValues ...interface{}) {
+	d.l.Error(formatLine(LevelError, msg, keysAndValues))
+}
+
+// print writes lines below the error level through Printf, if the logger has it
+func (d debugModeLogger) print(level LogLevel, msg string, keysAndValues []interface{}) {
+	if l, ok := d.l.(printfer); ok {
+		l.Printf("%s", formatLine(level, msg, keysAndValues))
+	}
+}
+
+// NewStdLogger adapts a standard library *log.Logger, dropping lines below the given level.
+// A nil logger writes through the log package's default logger.
+func NewStdLogger(l *log.Logger, level LogLevel) LeveledLogger {
+	if l == nil {
+		l = log.Default()
+	}
+	return printfLogger{printf: l.Printf, level: level}
+}
+
+// printfLogger renders leveled log lines as "[highlight-go] LEVEL msg key=value ..."
+type printfLogger struct {
+	printf func(format string, v ...interface{})
+	level  LogLevel
+}
+
+func (p printfLogger) Debug(msg string, keysAndValues ...interface{}) {
+	p.log(LevelDebug, msg, keysAndValues)
+}
+
+func (p printfLogger) Info(msg string, keysAndValues ...interface{}) {
+	p.log(LevelInfo, msg, keysAndValues)
+}
+
+func (p printfLogger) Warn(msg string, keysAndValues ...interface{}) {
+	p.log(LevelWarn, msg, keysAndValues)
+}
+
+func (p printfLogger) Error(msg string, keysAndValues ...interface{}) {
+	p.log(LevelError, msg, keysAndValues)
+}
+
+func (p printfLogger) log(level LogLevel, msg string, keysAndValues []interface{}) {
+	if level < p.level {
+		return
+	}
+	p.printf("%s", formatLine(level, msg, keysAndValues))
+}
+
+// formatLine renders a log line as "[highlight-go] LEVEL msg key=value ..."
+func formatLine(level LogLevel, msg string, keysAndValues []interface{}) string {
+	return fmt.Sprintf("[highlight-go] %s %s%s", level, msg, formatKeysAndValues(keysAndValues))
+}
+
+// formatKeysAndValues renders alternating key/value pairs as " key=value key2=value2".
+// A trailing key without a value is rendered with a value of "!MISSING".
+func formatKeysAndValues(keysAndValues []interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "!MISSING"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", keysAndValues[i], value)
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package highlight
+
+import (
+	"context"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// LogInput is a log line recorded with Log
+type LogInput struct {
+	SessionSecureID graphql.String  `json:"session_secure_id"`
+	RequestID       graphql.String  `json:"request_id"`
+	Level           graphql.String  `json:"level"`
+	Message         graphql.String  `json:"message"`
+	Timestamp       time.Time       `json:"timestamp"`
+	Attributes      []MetricTag     `json:"attributes,omitempty"`
+	ServiceName     graphql.String  `json:"service_name,omitempty"`
+	Environment     graphql.String  `json:"environment,omitempty"`
+	Hostname        graphql.String  `json:"hostname,omitempty"`
+	SessionAbsent   graphql.Boolean `json:"session_absent,omitempty"`
+	TraceID         graphql.String  `json:"trace_id,omitempty"`
+	SpanID          graphql.String  `json:"span_id,omitempty"`
+}
+
+// Log records a log line in the context of the session and request carried by ctx.
+// The line is kept as a breadcrumb, so an error consumed later in the same request shows
+// what led up to it, and is sent as a log record when exporting over OTLP (see SetExportProtocol):
+//
+//	highlight.Log(ctx, highlight.LevelInfo, "charging card", highlight.Attr("amount", 42))
+func Log(ctx context.Context, level LogLevel, msg string, attrs ...Attribute) {
+	timestamp := time.Now().UTC()
+	breadcrumbsFromContext(ctx).add(Breadcrumb{
+		Type:      BreadcrumbLog,
+		Level:     level.String(),
+		Message:   msg,
+		Timestamp: timestamp,
+		Data:      attributeMap(attrs),
+	})
+	// the GraphQL API has no mutation for log lines
+	if _, ok := requester.(graphqlRequester); ok {
+		return
+	}
+
+	sessionSecureID, requestID, err := validateRequest(ctx)
+	if err != nil {
+		stats.drop(dropReasonForValidation(err))
+		logger.Warn("discarding log", "reason", err)
+		return
+	}
+	defer wg.Done()
+	wg.Add(1)
+
+	input := LogInput{
+		SessionSecureID: graphql.String(sessionSecureID),
+		RequestID:       graphql.String(requestID),
+		Level:           graphql.String(level.String()),
+		Message:         graphql.String(msg),
+		Timestamp:       timestamp,
+	}
+	for _, a := range attrs {
+		input.Attributes = append(input.Attributes, MetricTag{Name: graphql.String(a.Key), Value: graphql.String(a.Value)})
+	}
+	if backendOnlyMode {
+		input.ServiceName = graphql.String(serviceName)
+		input.Environment = graphql.String(environment)
+		input.Hostname = graphql.String(hostname)
+		input.SessionAbsent = sessionSecureID == ""
+	}
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		input.TraceID = graphql.String(tc.TraceID)
+		input.SpanID = graphql.String(tc.SpanID)
+	}
+	select {
+	case logChan <- input:
+		stats.logsEnqueued.Add(1)
+		logger.Debug("log enqueued", "session_secure_id", sessionSecureID, "request_id", requestID)
+	default:
+		stats.drop(DropReasonChannelFull)
+		logger.Error("log channel full, discarding value", "session_secure_id", sessionSecureID)
+	}
+}
+
+func flushLogs() []*LogInput {
+	tempChanSize := len(logChan)
+	var flushedLogs []*LogInput
+	for i := 0; i < tempChanSize; i++ {
+		l := <-logChan
+		flushedLogs = append(flushedLogs, &l)
+	}
+	return flushedLogs
+}
+
+func attributeMap(attrs []Attribute) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[a.Key] = a.Value
+	}
+	return m
+}
@@ -0,0 +1,43 @@
+//go:build go1.21
+
+package highlight
+
+import (
+	"context"
+	"log/slog"
+)
+
+// the attribute NewSlogLogger tags the SDK's own log lines with
+const (
+	sdkAttrKey   = "sdk"
+	sdkAttrValue = "highlight-go"
+)
+
+// NewSlogLogger adapts a *slog.Logger for the SDK's internal logging.
+// Lines are tagged with sdk=highlight-go. A nil logger uses slog.Default().
+func NewSlogLogger(l *slog.Logger) LeveledLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l: l.With(sdkAttrKey, sdkAttrValue)}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelDebug, msg, keysAndValues...)
+}
+
+func (s slogLogger) Info(msg string, keysAndValues ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelInfo, msg, keysAndValues...)
+}
+
+func (s slogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelWarn, msg, keysAndValues...)
+}
+
+func (s slogLogger) Error(msg string, keysAndValues ...interface{}) {
+	s.l.Log(context.Background(), slog.LevelError, msg, keysAndValues...)
+}
@@ -0,0 +1,33 @@
+//go:build go1.21
+
+package highlight
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	l.Debug("hidden")
+	l.Warn("discarding error", "reason", "no session")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single JSON record: %v: %s", err, buf.String())
+	}
+	expected := map[string]interface{}{
+		"level":  "WARN",
+		"msg":    "discarding error",
+		"reason": "no session",
+		"sdk":    "highlight-go",
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("wrong %s [%v != %v]", key, record[key], value)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package highlight
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), LevelInfo)
+	l.Debug("hidden", "errors", 1)
+	l.Info("exported batch", "errors", 2, "metrics")
+	l.Error("error exporting batch", "error", "boom")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"[highlight-go] INFO exported batch errors=2 metrics=!MISSING",
+		"[highlight-go] ERROR error exporting batch error=boom",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong number of log lines [%v != %v]: %q", len(lines), len(expected), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("log line not equal to expected line: %q != %q", lines[i], expected[i])
+		}
+	}
+}
+
+// errorLogger only has the methods of Logger
+type errorLogger struct {
+	lines []string
+}
+
+func (l *errorLogger) Error(v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprint(v...))
+}
+
+func (l *errorLogger) Errorf(format string, v ...interface{}) {
+	l.lines = append(l.lines, "errorf: "+fmt.Sprintf(format, v...))
+}
+
+// leveledPrintfLogger also has a method for info lines
+type leveledPrintfLogger struct {
+	errorLogger
+}
+
+func (l *leveledPrintfLogger) Infof(format string, v ...interface{}) {
+	l.lines = append(l.lines, "infof: "+fmt.Sprintf(format, v...))
+}
+
+func TestDebugMode(t *testing.T) {
+	defer SetLogger(nil)
+
+	errs := &errorLogger{}
+	SetDebugMode(errs)
+	logger.Info("exported batch", "errors", 2)
+	logger.Error("error exporting batch", "error", "boom")
+	if len(errs.lines) != 1 || errs.lines[0] != "[highlight-go] ERROR error exporting batch error=boom" {
+		t.Errorf("levels not routed to the matching methods: %q", errs.lines)
+	}
+
+	leveled := &leveledPrintfLogger{}
+	SetDebugMode(leveled)
+	logger.Info("exported batch", "errors", 2)
+	logger.Debug("flushing")
+	if len(leveled.lines) != 1 || leveled.lines[0] != "infof: [highlight-go] INFO exported batch errors=2" {
+		t.Errorf("info line not routed to Infof: %q", leveled.lines)
+	}
+}
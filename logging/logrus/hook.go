@@ -0,0 +1,70 @@
+package logrus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// Hook is a logrus hook that reports entries with highlight.ConsumeError
+// use as follows:
+//
+// import highlightlogrus "github.com/highlight-run/highlight-go/logging/logrus"
+// ...
+// logrus.AddHook(highlightlogrus.NewHook())
+// ...
+// logrus.WithContext(ctx).WithError(err).Error("charge failed")
+//
+// The entry's context carries the highlight IDs, its error field is reported as the error
+// (the message is used when there is none) and its other fields become tags. Panic and fatal
+// entries are exported before Fire returns, since logrus panics or exits right after.
+type Hook struct {
+	levels []logrus.Level
+}
+
+// NewHook returns a Hook reporting entries at the given levels,
+// or at error level and above if none are given.
+func NewHook(levels ...logrus.Level) *Hook {
+	if len(levels) == 0 {
+		levels = []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+	}
+	return &Hook{levels: levels}
+}
+
+// Levels implements logrus.Hook
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire implements logrus.Hook
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var errorInput interface{} = entry.Message
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]string, 0, len(keys)+2)
+	for _, k := range keys {
+		if err, ok := entry.Data[k].(error); ok && k == logrus.ErrorKey {
+			errorInput = err
+			tags = append(tags, "message:"+entry.Message)
+			continue
+		}
+		tags = append(tags, fmt.Sprintf("%s:%v", k, entry.Data[k]))
+	}
+	tags = append(tags, "level:"+entry.Level.String())
+	highlight.ConsumeError(ctx, errorInput, tags...)
+	if entry.Level <= logrus.FatalLevel {
+		highlight.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package logrus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+func TestHook(t *testing.T) {
+	var reported []*highlight.BackendErrorObjectInput
+	highlight.OnError(func(ctx context.Context, e *highlight.BackendErrorObjectInput) {
+		reported = append(reported, e)
+	})
+	highlight.Start()
+	defer highlight.Stop()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(NewHook())
+
+	ctx := highlight.WithSession(context.Background(), "session", "request")
+	logger.WithContext(ctx).Info("not reported")
+	logger.WithContext(ctx).WithError(fmt.Errorf("charge failed")).WithField("amount", 42).Error("billing")
+
+	if len(reported) != 1 {
+		t.Fatalf("wrong number of reported errors [%v != %v]", len(reported), 1)
+	}
+	e := reported[0]
+	if e.Event != "charge failed" || e.SessionSecureID != "session" || e.RequestID != "request" {
+		t.Errorf("wrong error reported: %+v", e)
+	}
+	if e.Payload == nil || !strings.Contains(string(*e.Payload), "amount:42") || !strings.Contains(string(*e.Payload), "message:billing") {
+		t.Errorf("fields not reported as tags: %v", e.Payload)
+	}
+}
+
+func TestHookFlushesPanics(t *testing.T) {
+	var pushed int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushed, 1)
+		_, _ = w.Write([]byte(`{"data":{"pushBackendPayload":""}}`))
+	}))
+	defer backend.Close()
+	highlight.SetGraphqlClientAddress(backend.URL)
+	highlight.Start()
+	defer highlight.Stop()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(NewHook())
+
+	ctx := highlight.WithSession(context.Background(), "session", "request")
+	func() {
+		defer func() { _ = recover() }()
+		logger.WithContext(ctx).Panic("corrupt state")
+	}()
+	if atomic.LoadInt32(&pushed) != 1 {
+		t.Errorf("panic entry not exported before logrus panicked")
+	}
+}
@@ -0,0 +1,117 @@
+package zap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// contextKey is the key of the field added by Context
+const contextKey = "highlight.context"
+
+// Context returns a field carrying ctx, so entries logged with it are tied to the
+// highlight session of the context. The field is never written by encoders.
+func Context(ctx context.Context) zap.Field {
+	return zap.Field{Key: contextKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// Core is a zapcore.Core that forwards entries to another core and reports entries at or
+// above a level with highlight.ConsumeError
+// use as follows:
+//
+// import highlightzap "github.com/highlight-run/highlight-go/logging/zap"
+// ...
+// logger := zap.New(highlightzap.NewCore(core, zapcore.ErrorLevel))
+// ...
+// logger.Error("charge failed", zap.Error(err), highlightzap.Context(ctx))
+//
+// The entry's error field is reported as the error (the message is used when there is none)
+// and its other fields become tags. Entries at DPanic level and above are exported before
+// Write returns, since zap may panic or exit right after.
+type Core struct {
+	zapcore.Core
+	level  zapcore.LevelEnabler
+	fields []zapcore.Field
+}
+
+// NewCore wraps next, reporting entries enabled by level
+func NewCore(next zapcore.Core, level zapcore.LevelEnabler) *Core {
+	return &Core{Core: next, level: level}
+}
+
+// Enabled implements zapcore.Core
+func (c *Core) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) || c.Core.Enabled(level)
+}
+
+// With implements zapcore.Core
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	return &Core{
+		Core:   c.Core.With(fields),
+		level:  c.level,
+		fields: append(append([]zapcore.Field{}, c.fields...), fields...),
+	}
+}
+
+// Check implements zapcore.Core
+func (c *Core) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.level.Enabled(entry.Level) {
+		ce = ce.AddCore(entry, reportingCore{c})
+	}
+	return c.Core.Check(entry, ce)
+}
+
+// reportingCore is added to checked entries so Write only reports, while the
+// wrapped core writes the entry itself through its own Check
+type reportingCore struct {
+	*Core
+}
+
+// Write implements zapcore.Core
+func (r reportingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	ctx := context.Background()
+	var errorInput interface{} = entry.Message
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range append(append([]zapcore.Field{}, r.fields...), fields...) {
+		switch {
+		case f.Key == contextKey && f.Type == zapcore.SkipType:
+			if c, ok := f.Interface.(context.Context); ok {
+				ctx = c
+			}
+		case f.Type == zapcore.ErrorType && f.Key == "error":
+			if err, ok := f.Interface.(error); ok {
+				errorInput = err
+			}
+		default:
+			f.AddTo(enc)
+		}
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]string, 0, len(keys)+2)
+	for _, k := range keys {
+		tags = append(tags, fmt.Sprintf("%s:%v", k, enc.Fields[k]))
+	}
+	if _, ok := errorInput.(error); ok {
+		tags = append(tags, "message:"+entry.Message)
+	}
+	tags = append(tags, "level:"+entry.Level.String())
+	highlight.ConsumeError(ctx, errorInput, tags...)
+	if entry.Level >= zapcore.DPanicLevel {
+		highlight.Flush()
+	}
+	return nil
+}
+
+// Sync implements zapcore.Core
+func (r reportingCore) Sync() error {
+	return nil
+}
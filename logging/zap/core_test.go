@@ -0,0 +1,47 @@
+package zap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+func TestCore(t *testing.T) {
+	var reported []*highlight.BackendErrorObjectInput
+	highlight.OnError(func(ctx context.Context, e *highlight.BackendErrorObjectInput) {
+		reported = append(reported, e)
+	})
+	highlight.Start()
+	defer highlight.Stop()
+
+	next, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(NewCore(next, zapcore.ErrorLevel)).With(zap.String("service", "billing"))
+
+	ctx := highlight.WithSession(context.Background(), "session", "request")
+	logger.Info("not reported", Context(ctx))
+	logger.Error("charge failed", zap.Error(fmt.Errorf("card declined")), zap.Int("amount", 42), Context(ctx))
+
+	if logs.Len() != 2 {
+		t.Errorf("entries not written to the wrapped core [%v != %v]", logs.Len(), 2)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("wrong number of reported errors [%v != %v]", len(reported), 1)
+	}
+	e := reported[0]
+	if e.Event != "card declined" || e.SessionSecureID != "session" || e.RequestID != "request" {
+		t.Errorf("wrong error reported: %+v", e)
+	}
+	payload := string(*e.Payload)
+	for _, tag := range []string{"amount:42", "service:billing", "message:charge failed"} {
+		if !strings.Contains(payload, tag) {
+			t.Errorf("tag %q missing from %v", tag, payload)
+		}
+	}
+}
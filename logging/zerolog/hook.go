@@ -0,0 +1,122 @@
+package zerolog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rs/zerolog"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// Hook is a zerolog hook that adds the highlight session and request IDs from the event's
+// context to every event, so they appear in the log line and reach Writer.
+// use as follows:
+//
+// import highlightzerolog "github.com/highlight-run/highlight-go/logging/zerolog"
+// ...
+// logger := zerolog.New(highlightzerolog.NewWriter(os.Stdout, zerolog.ErrorLevel)).Hook(highlightzerolog.Hook{})
+// ...
+// logger.Error().Ctx(ctx).Err(err).Msg("charge failed")
+type Hook struct{}
+
+// Run implements zerolog.Hook
+func (h Hook) Run(e *zerolog.Event, level zerolog.Level, message string) {
+	ctx := e.GetCtx()
+	if ctx == nil {
+		return
+	}
+	if session, ok := highlight.SessionFromContext(ctx); ok {
+		e.Str(highlight.BaggageSessionSecureID, session.SecureID)
+		e.Str(highlight.BaggageRequestID, session.RequestID)
+	}
+}
+
+// Writer is a zerolog.LevelWriter that writes log lines to another writer and reports
+// lines at or above a level with highlight.ConsumeError. zerolog hooks cannot read the
+// fields of an event, so the JSON line is decoded instead: its error field is reported
+// as the error (the message is used when there is none), the IDs added by Hook tie it
+// to the session and its other fields become tags. Fatal and panic lines are exported
+// before WriteLevel returns, since zerolog exits or panics right after.
+type Writer struct {
+	next  zerolog.LevelWriter
+	level zerolog.Level
+}
+
+// NewWriter wraps next, reporting lines at or above level
+func NewWriter(next io.Writer, level zerolog.Level) *Writer {
+	lw, ok := next.(zerolog.LevelWriter)
+	if !ok {
+		lw = levelWriterAdapter{next}
+	}
+	return &Writer{next: lw, level: level}
+}
+
+// Write implements io.Writer, writing p without reporting it
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.next.Write(p)
+}
+
+// WriteLevel implements zerolog.LevelWriter
+func (w *Writer) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level >= w.level && level < zerolog.NoLevel {
+		w.report(level, p)
+	}
+	return w.next.WriteLevel(level, p)
+}
+
+func (w *Writer) report(level zerolog.Level, p []byte) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return
+	}
+	ctx := context.Background()
+	sessionSecureID, _ := fields[highlight.BaggageSessionSecureID].(string)
+	requestID, _ := fields[highlight.BaggageRequestID].(string)
+	if sessionSecureID != "" {
+		ctx = highlight.WithSession(ctx, sessionSecureID, requestID)
+	}
+	message, _ := fields[zerolog.MessageFieldName].(string)
+	var errorInput interface{} = message
+	if errorMessage, ok := fields[zerolog.ErrorFieldName].(string); ok {
+		errorInput = fmt.Errorf("%s", errorMessage)
+	}
+
+	delete(fields, highlight.BaggageSessionSecureID)
+	delete(fields, highlight.BaggageRequestID)
+	delete(fields, zerolog.MessageFieldName)
+	delete(fields, zerolog.ErrorFieldName)
+	delete(fields, zerolog.LevelFieldName)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]string, 0, len(keys)+2)
+	for _, k := range keys {
+		tags = append(tags, fmt.Sprintf("%s:%v", k, fields[k]))
+	}
+	if _, ok := errorInput.(error); ok && message != "" {
+		tags = append(tags, "message:"+message)
+	}
+	tags = append(tags, "level:"+level.String())
+	highlight.ConsumeError(ctx, errorInput, tags...)
+	if level == zerolog.FatalLevel || level == zerolog.PanicLevel {
+		highlight.Flush()
+	}
+}
+
+type levelWriterAdapter struct {
+	w io.Writer
+}
+
+func (l levelWriterAdapter) Write(p []byte) (int, error) {
+	return l.w.Write(p)
+}
+
+func (l levelWriterAdapter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	return l.w.Write(p)
+}
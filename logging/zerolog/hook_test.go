@@ -0,0 +1,46 @@
+package zerolog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+func TestWriter(t *testing.T) {
+	var reported []*highlight.BackendErrorObjectInput
+	highlight.OnError(func(ctx context.Context, e *highlight.BackendErrorObjectInput) {
+		reported = append(reported, e)
+	})
+	highlight.Start()
+	defer highlight.Stop()
+
+	var out bytes.Buffer
+	logger := zerolog.New(NewWriter(&out, zerolog.ErrorLevel)).Hook(Hook{})
+
+	ctx := highlight.WithSession(context.Background(), "session", "request")
+	logger.Info().Ctx(ctx).Msg("not reported")
+	logger.Error().Ctx(ctx).Err(fmt.Errorf("card declined")).Int("amount", 42).Msg("charge failed")
+
+	if !strings.Contains(out.String(), `"highlight.session_secure_id":"session"`) {
+		t.Errorf("hook did not add the session to the log line: %v", out.String())
+	}
+	if len(reported) != 1 {
+		t.Fatalf("wrong number of reported errors [%v != %v]", len(reported), 1)
+	}
+	e := reported[0]
+	if e.Event != "card declined" || e.SessionSecureID != "session" || e.RequestID != "request" {
+		t.Errorf("wrong error reported: %+v", e)
+	}
+	payload := string(*e.Payload)
+	for _, tag := range []string{"amount:42", "message:charge failed"} {
+		if !strings.Contains(payload, tag) {
+			t.Errorf("tag %q missing from %v", tag, payload)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package highlight
+
+import (
+	"context"
+	"net/http"
+)
+
+// MiddlewareRequest is the framework-agnostic core of the router middlewares. It intercepts
+// the request's highlight headers, injects the IDs, route and capture into its context, marks
+// the backend as set up, recovers panics and records the request (see SetServerMetrics,
+// SetReportServerErrors and SetRequestCapture). A middleware starts one per request, hands
+// Context to the handlers the way its framework expects and ends it from a deferred function:
+//
+//	m := highlight.StartMiddlewareRequest(r.Context(), r, route)
+//	rw := m.WrapResponseWriter(w)
+//	defer func() {
+//		if m.Recover(recover()) && !rw.Written() {
+//			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+//		}
+//		m.End(rw.Status(), rw.BytesWritten())
+//	}()
+//	next.ServeHTTP(rw, r.WithContext(m.Context()))
+type MiddlewareRequest struct {
+	ctx      context.Context
+	server   *ServerRequest
+	panicked bool
+}
+
+// StartMiddlewareRequest starts handling r, which matched the route template route, or an
+// unknown route if empty. ctx is the context the highlight values are added to, usually
+// r.Context(). Start it before handing r to the handlers, since it wraps r.Body.
+func StartMiddlewareRequest(ctx context.Context, r *http.Request, route string) *MiddlewareRequest {
+	ctx = InterceptRequestWithContext(ctx, r)
+	if route != "" {
+		ctx = WithRoute(ctx, route)
+	}
+	if _, ok := SessionFromContext(ctx); ok {
+		MarkBackendSetup(ctx)
+	}
+	return &MiddlewareRequest{ctx: ctx, server: StartServerRequest(ctx, r, route)}
+}
+
+// Context returns the context carrying the highlight values of the request
+func (m *MiddlewareRequest) Context() context.Context {
+	return m.ctx
+}
+
+// Recording reports whether the request's metrics, 5xx errors or capture are being recorded,
+// for middlewares that must do extra work to feed them, e.g. replay a buffered response
+func (m *MiddlewareRequest) Recording() bool {
+	return m.server != nil
+}
+
+// SetRoute sets the route template of the request, for routers that only resolve the route
+// while the handler chain runs. Context carries it from then on.
+func (m *MiddlewareRequest) SetRoute(route string) {
+	if route == "" {
+		return
+	}
+	m.ctx = WithRoute(m.ctx, route)
+	m.server.SetRoute(route)
+}
+
+// WrapResponseWriter wraps w to record the status code and size of the response, and feed it
+// into the request's capture
+func (m *MiddlewareRequest) WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return m.server.WrapResponseWriter(w)
+}
+
+// Recover reports p, the value returned by recover(), with ConsumePanic and returns true
+// when it is a panic; the middleware should then respond 500 if nothing was written yet.
+// http.ErrAbortHandler, which handlers use to abort a response on purpose, is re-panicked.
+func (m *MiddlewareRequest) Recover(p interface{}) bool {
+	if p == nil {
+		return false
+	}
+	if p == http.ErrAbortHandler {
+		panic(p)
+	}
+	ConsumePanic(m.ctx, p)
+	m.panicked = true
+	m.server.Panicked()
+	return true
+}
+
+// End records the outcome of the request. A request that panicked is recorded as a 500.
+func (m *MiddlewareRequest) End(status int, responseBytes int64) {
+	if m.panicked {
+		status = http.StatusInternalServerError
+	}
+	m.server.End(status, responseBytes)
+}
+
+// HTTPMiddleware wraps next with the highlight middleware for net/http compatible routers.
+// route resolves the route template a request matched, e.g. /users/{id}, or "" if unknown;
+// it may be nil. Panics are reported and answered with a 500.
+func HTTPMiddleware(next http.Handler, route func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var template string
+		if route != nil {
+			template = route(r)
+		}
+		m := StartMiddlewareRequest(r.Context(), r, template)
+		rw := m.WrapResponseWriter(w)
+		defer func() {
+			if m.Recover(recover()) && !rw.Written() {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			m.End(rw.Status(), rw.BytesWritten())
+		}()
+		next.ServeHTTP(rw, r.WithContext(m.Context()))
+	})
+}
@@ -3,6 +3,8 @@ package chi
 import (
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
+
 	"github.com/highlight-run/highlight-go"
 )
 
@@ -13,12 +15,31 @@ import (
 // ...
 // r.Use(highlightchi.Middleware)
 //
+// It reports panics with highlight.ConsumePanic and responds 500.
+// With highlight.SetServerMetrics and highlight.SetReportServerErrors, it also records
+// request metrics and reports 5xx responses, named after the matched route pattern.
+// With highlight.SetRequestCapture, it captures requests and responses for those errors.
 func Middleware(next http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		ctx := highlight.InterceptRequest(r)
-		r = r.WithContext(ctx)
-		highlight.MarkBackendSetup(r.Context())
-		next.ServeHTTP(w, r)
+	return highlight.HTTPMiddleware(next, routePattern)
+}
+
+// routePattern resolves the pattern r will be routed to, e.g. /users/{id}.
+// chi only fills in RoutePattern while routing, after middlewares have run,
+// so the route is matched ahead of time against a scratch routing context.
+// rctx.Routes is always the root router, so the full path is matched even when
+// the middleware is mounted in a subrouter, where rctx.RoutePath is only what is left of it.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	scratch := chi.NewRouteContext()
+	if !rctx.Routes.Match(scratch, r.Method, path) {
+		return ""
 	}
-	return http.HandlerFunc(fn)
+	return scratch.RoutePattern()
 }
@@ -0,0 +1,67 @@
+package chi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/highlight-run/highlight-go"
+	"github.com/highlight-run/highlight-go/internal/conformance"
+)
+
+func TestRoutePattern(t *testing.T) {
+	var resolved string
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			resolved = routePattern(req)
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Route("/orgs/{org}", func(r chi.Router) {
+		r.Get("/projects/{project}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	for path, expected := range map[string]string{
+		"/users/123":              "/users/{id}",
+		"/orgs/acme/projects/web": "/orgs/{org}/projects/{project}",
+		"/nowhere":                "",
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if resolved != expected {
+			t.Errorf("wrong route pattern for %s [%q != %q]", path, resolved, expected)
+		}
+	}
+}
+
+func TestSubrouter(t *testing.T) {
+	var route string
+	r := chi.NewRouter()
+	r.Route("/users", func(r chi.Router) {
+		r.Use(Middleware)
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			route, _ = highlight.RouteFromContext(r.Context())
+		})
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/123", nil))
+	if route != "/users/{id}" {
+		t.Errorf("wrong route inside a subrouter [%q != %q]", route, "/users/{id}")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Adapter{
+		Serve: func(routes []conformance.Route) http.Handler {
+			r := chi.NewRouter()
+			r.Use(Middleware)
+			for _, route := range routes {
+				r.Method(route.Method, route.Path, conformance.HTTPHandler(route))
+			}
+			return r
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package echo
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// Middleware is an echo compatible middleware
+// use as follows:
+//
+// import highlightecho "github.com/highlight-run/highlight-go/middleware/echo"
+// ...
+// e.Use(highlightecho.Middleware())
+//
+// It injects the highlight IDs into c.Request().Context(), and reports panics with
+// highlight.ConsumePanic before handing them to the echo error handler as a 500.
+// With highlight.SetServerMetrics and highlight.SetReportServerErrors, it also records
+// request metrics and reports 5xx responses, named after the matched route.
+// With highlight.SetRequestCapture, it captures requests and responses for those errors.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			// middlewares added with Use run after routing, so Path is the matched route
+			m := highlight.StartMiddlewareRequest(c.Request().Context(), c.Request(), c.Path())
+			c.SetRequest(c.Request().WithContext(m.Context()))
+			res := c.Response()
+			if m.Recording() {
+				res.Writer = m.WrapResponseWriter(res.Writer)
+			}
+			defer func() {
+				if p := recover(); m.Recover(p) {
+					err = echo.ErrInternalServerError.WithInternal(fmt.Errorf("panic: %v", p))
+				}
+				// write the error response now so its status is the one recorded, and return
+				// nil so echo does not hand the error to its error handler a second time
+				if err != nil && !res.Committed {
+					c.Error(err)
+					err = nil
+				}
+				m.End(res.Status, res.Size)
+			}()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package echo
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/highlight-run/highlight-go/internal/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	var errorHandlerCalls int32
+	conformance.Run(t, conformance.Adapter{
+		Template: conformance.Colon,
+		Serve: func(routes []conformance.Route) http.Handler {
+			e := echo.New()
+			e.HTTPErrorHandler = func(err error, c echo.Context) {
+				atomic.AddInt32(&errorHandlerCalls, 1)
+				e.DefaultHTTPErrorHandler(err, c)
+			}
+			e.Use(Middleware())
+			for _, route := range routes {
+				handle := route.Handle
+				e.Add(route.Method, conformance.Colon(route.Path), func(c echo.Context) error {
+					body, _ := io.ReadAll(c.Request().Body)
+					status, response := handle(c.Request().Context(), body)
+					return c.String(status, response)
+				})
+			}
+			return e
+		},
+		ErrorHandlerCalls: func() int { return int(atomic.LoadInt32(&errorHandlerCalls)) },
+	})
+}
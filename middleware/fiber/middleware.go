@@ -0,0 +1,96 @@
+package fiber
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// Middleware is a fiber compatible middleware
+// use as follows:
+//
+// import highlightfiber "github.com/highlight-run/highlight-go/middleware/fiber"
+// ...
+// app.Use(highlightfiber.Middleware())
+//
+// fiber does not use net/http, so the highlight context is built from the request headers
+// and stored as the user context: pass c.UserContext() to highlight.ConsumeError.
+// Panics are reported with highlight.ConsumePanic and answered by the app's error handler
+// with a 500. Errors returned by the handlers are passed to the app's error handler here,
+// so they are not seen by middlewares registered before this one.
+// The route is only known once the handler chain has run, so requests are not counted in flight.
+// With highlight.SetServerMetrics and highlight.SetReportServerErrors, it also records
+// request metrics and reports 5xx responses, named after the matched route.
+// With highlight.SetRequestCapture, it captures requests and responses for those errors.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		var r http.Request
+		if err := fasthttpadaptor.ConvertRequest(c.Context(), &r, true); err != nil {
+			return err
+		}
+		m := highlight.StartMiddlewareRequest(c.UserContext(), &r, "")
+		c.SetUserContext(m.Context())
+		// the route is only resolved as the handler chain runs; until then c.Route() is the
+		// route this middleware was registered with
+		own := c.Route()
+		if m.Recording() {
+			// the body is already in memory; reading it feeds the capture and the byte count
+			_, _ = io.Copy(io.Discard, r.Body)
+		}
+		defer func() {
+			if m.Recover(recover()) {
+				err = fiber.ErrInternalServerError
+			}
+			// handle the error now, like fiber's logger, so its response is the one recorded
+			if err != nil {
+				if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+					_ = c.SendStatus(fiber.StatusInternalServerError)
+				}
+				err = nil
+			}
+			if !m.Recording() {
+				return
+			}
+			if route := c.Route(); route != nil && route != own {
+				m.SetRoute(route.Path)
+			}
+			res := c.Response()
+			rw := m.WrapResponseWriter(&responseRecorder{header: http.Header{}})
+			res.Header.VisitAll(func(key, value []byte) {
+				rw.Header().Add(string(key), string(value))
+			})
+			rw.WriteHeader(res.StatusCode())
+			size := int64(res.Header.ContentLength())
+			// reading a streamed body would buffer all of it
+			if !res.IsBodyStream() {
+				n, _ := rw.Write(res.Body())
+				size = int64(n)
+			}
+			if size < 0 {
+				size = 0
+			}
+			m.End(res.StatusCode(), size)
+		}()
+		return c.Next()
+	}
+}
+
+// responseRecorder is the http.ResponseWriter the buffered fiber response is replayed into,
+// so it reaches the request's capture
+type responseRecorder struct {
+	header http.Header
+}
+
+func (w *responseRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *responseRecorder) WriteHeader(int) {}
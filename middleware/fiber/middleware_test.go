@@ -0,0 +1,79 @@
+package fiber
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/highlight-run/highlight-go"
+	"github.com/highlight-run/highlight-go/internal/conformance"
+)
+
+func TestHandlerErrors(t *testing.T) {
+	for _, recording := range []bool{false, true} {
+		highlight.SetServerMetrics(recording)
+		var seen error
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			seen = c.Next()
+			return seen
+		})
+		app.Use(Middleware())
+		app.Get("/", func(c *fiber.Ctx) error {
+			return fiber.ErrTeapot
+		})
+		w := httptest.NewRecorder()
+		appHandler{app}.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusTeapot || seen != nil {
+			t.Errorf("handler error not handled by the middleware while recording is %v: %v %v", recording, w.Code, seen)
+		}
+	}
+	highlight.SetServerMetrics(false)
+}
+
+func TestConformance(t *testing.T) {
+	var errorHandlerCalls int32
+	conformance.Run(t, conformance.Adapter{
+		Template: conformance.Colon,
+		Serve: func(routes []conformance.Route) http.Handler {
+			app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+				atomic.AddInt32(&errorHandlerCalls, 1)
+				return fiber.DefaultErrorHandler(c, err)
+			}})
+			app.Use(Middleware())
+			for _, route := range routes {
+				handle := route.Handle
+				app.Add(route.Method, conformance.Colon(route.Path), func(c *fiber.Ctx) error {
+					status, response := handle(c.UserContext(), c.Body())
+					return c.Status(status).SendString(response)
+				})
+			}
+			return appHandler{app}
+		},
+		LateRoute:         true,
+		ErrorHandlerCalls: func() int { return int(atomic.LoadInt32(&errorHandlerCalls)) },
+	})
+}
+
+// appHandler serves a fiber app to net/http requests
+type appHandler struct {
+	app *fiber.App
+}
+
+func (h appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res, err := h.app.Test(r, -1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+	for key, values := range res.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, res.Body)
+}
@@ -0,0 +1,48 @@
+package gin
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// ErrorReporter is a gin middleware that reports the errors attached to the context with
+// c.Error once the rest of the chain has run. Register it after Middleware:
+//
+// r.Use(highlightgin.Middleware(), highlightgin.ErrorReporter())
+func ErrorReporter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		ConsumeErrors(c)
+	}
+}
+
+// ConsumeErrors reports every error in c.Errors with highlight.ConsumeError, using the
+// context of c.Request, tagged with its gin error type and any metadata
+func ConsumeErrors(c *gin.Context) {
+	ctx := c.Request.Context()
+	for _, e := range c.Errors {
+		tags := []string{"source:gin", "gin_error_type:" + errorTypeName(e.Type)}
+		if e.Meta != nil {
+			tags = append(tags, fmt.Sprintf("meta:%v", e.Meta))
+		}
+		highlight.ConsumeError(ctx, e.Err, tags...)
+	}
+}
+
+func errorTypeName(t gin.ErrorType) string {
+	switch {
+	case t&gin.ErrorTypeBind != 0:
+		return "bind"
+	case t&gin.ErrorTypeRender != 0:
+		return "render"
+	case t&gin.ErrorTypePublic != 0:
+		return "public"
+	case t&gin.ErrorTypePrivate != 0:
+		return "private"
+	default:
+		return fmt.Sprintf("%d", uint64(t))
+	}
+}
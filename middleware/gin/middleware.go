@@ -1,7 +1,7 @@
 package gin
 
 import (
-	"strings"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 
@@ -15,15 +15,57 @@ import (
 // ...
 // r.Use(highlightgin.Middleware())
 //
+// Pass c.Request.Context() to highlight.ConsumeError, or use ErrorReporter to report the
+// errors attached with c.Error.
+//
+// It reports panics with highlight.ConsumePanic and responds 500.
+// With highlight.SetServerMetrics and highlight.SetReportServerErrors, it also records
+// request metrics and reports 5xx responses, named after the matched route.
+// With highlight.SetRequestCapture, it captures requests and responses for those errors.
 func Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		highlightReqDetails := c.GetHeader("X-Highlight-Request")
-		ids := strings.Split(highlightReqDetails, "/")
-		if len(ids) < 2 {
-			return
+		// FullPath is empty when no route matched
+		m := highlight.StartMiddlewareRequest(c.Request.Context(), c.Request, c.FullPath())
+		ctx := m.Context()
+		// code handed c.Request.Context(), such as gqlgen handlers, database layers
+		// and outgoing HTTP calls, needs the IDs as much as code handed c
+		c.Request = c.Request.WithContext(ctx)
+		if session, ok := highlight.SessionFromContext(ctx); ok {
+			c.Set(string(highlight.ContextKeys.SessionSecureID), session.SecureID)
+			c.Set(string(highlight.ContextKeys.RequestID), session.RequestID)
+		}
+		if m.Recording() {
+			c.Writer = &captureWriter{ResponseWriter: c.Writer, rw: m.WrapResponseWriter(c.Writer)}
 		}
-		c.Set(string(highlight.ContextKeys.SessionSecureID), ids[0])
-		c.Set(string(highlight.ContextKeys.RequestID), ids[1])
-		highlight.MarkBackendSetup(c)
+		defer func() {
+			if m.Recover(recover()) && !c.Writer.Written() {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+			size := c.Writer.Size()
+			if size < 0 {
+				size = 0
+			}
+			m.End(c.Writer.Status(), int64(size))
+		}()
+		c.Next()
 	}
 }
+
+// captureWriter routes what handlers write through a highlight.ResponseWriter,
+// which feeds the response into the request's capture buffer
+type captureWriter struct {
+	gin.ResponseWriter
+	rw *highlight.ResponseWriter
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	w.rw.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	return w.rw.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	return w.rw.Write([]byte(s))
+}
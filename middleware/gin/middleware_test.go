@@ -0,0 +1,85 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/highlight-run/highlight-go"
+	"github.com/highlight-run/highlight-go/internal/conformance"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var reported []*highlight.BackendErrorObjectInput
+	highlight.OnError(func(ctx context.Context, e *highlight.BackendErrorObjectInput) {
+		reported = append(reported, e)
+	})
+	// MarkBackendSetup calls the GraphQL API on the first request with a session
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"markBackendSetup":""}}`))
+	}))
+	defer backend.Close()
+	highlight.SetGraphqlClientAddress(backend.URL)
+	highlight.Start()
+	defer highlight.Stop()
+
+	var session highlight.Session
+	var route string
+	r := gin.New()
+	r.Use(Middleware(), ErrorReporter())
+	r.GET("/users/:id", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		session, _ = highlight.SessionFromContext(ctx)
+		route, _ = highlight.RouteFromContext(ctx)
+		_ = c.Error(errors.New("lookup failed")).SetType(gin.ErrorTypePublic).SetMeta("users")
+		c.Status(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest("GET", "/users/123", nil)
+	req.Header.Set("X-Highlight-Request", "session/request")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if session.SecureID != "session" || session.RequestID != "request" {
+		t.Errorf("IDs not propagated into c.Request.Context(): %+v", session)
+	}
+	if route != "/users/:id" {
+		t.Errorf("wrong route in c.Request.Context() [%q != %q]", route, "/users/:id")
+	}
+	if len(reported) != 1 {
+		t.Fatalf("wrong number of reported errors [%v != %v]", len(reported), 1)
+	}
+	e := reported[0]
+	if e.Event != "lookup failed" || e.SessionSecureID != "session" || e.RequestID != "request" {
+		t.Errorf("wrong error reported: %+v", e)
+	}
+	if e.Payload == nil || !strings.Contains(string(*e.Payload), "gin_error_type:public") || !strings.Contains(string(*e.Payload), "meta:users") {
+		t.Errorf("gin error details not reported as tags: %v", e.Payload)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conformance.Run(t, conformance.Adapter{
+		Template: conformance.Colon,
+		Serve: func(routes []conformance.Route) http.Handler {
+			r := gin.New()
+			r.Use(Middleware())
+			for _, route := range routes {
+				handle := route.Handle
+				r.Handle(route.Method, conformance.Colon(route.Path), func(c *gin.Context) {
+					body, _ := io.ReadAll(c.Request.Body)
+					status, response := handle(c.Request.Context(), body)
+					c.String(status, response)
+				})
+			}
+			return r
+		},
+	})
+}
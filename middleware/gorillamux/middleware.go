@@ -3,6 +3,8 @@ package gorillamux
 import (
 	"net/http"
 
+	"github.com/gorilla/mux"
+
 	"github.com/highlight-run/highlight-go"
 )
 
@@ -13,12 +15,24 @@ import (
 // ...
 // r.Use(highlightgorilla.Middleware)
 //
+// It reports panics with highlight.ConsumePanic and responds 500.
+// With highlight.SetServerMetrics and highlight.SetReportServerErrors, it also records
+// request metrics and reports 5xx responses, named after the matched path template.
+// With highlight.SetRequestCapture, it captures requests and responses for those errors.
 func Middleware(next http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		ctx := highlight.InterceptRequest(r)
-		r = r.WithContext(ctx)
-		highlight.MarkBackendSetup(r.Context())
-		next.ServeHTTP(w, r)
+	return highlight.HTTPMiddleware(next, pathTemplate)
+}
+
+// pathTemplate returns the template of the route r matched, e.g. /users/{id}.
+// Middlewares added with Router.Use run after matching, so the route is known.
+func pathTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
 	}
-	return http.HandlerFunc(fn)
+	return template
 }
@@ -0,0 +1,23 @@
+package gorillamux
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/highlight-run/highlight-go/internal/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Adapter{
+		Serve: func(routes []conformance.Route) http.Handler {
+			r := mux.NewRouter()
+			r.Use(Middleware)
+			for _, route := range routes {
+				r.Handle(route.Path, conformance.HTTPHandler(route)).Methods(route.Method)
+			}
+			return r
+		},
+	})
+}
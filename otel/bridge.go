@@ -0,0 +1,52 @@
+package otel
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hasura/go-graphql-client"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// EnableErrorBridge makes highlight.ConsumeError record every error as an exception event
+// on the active OpenTelemetry span of its context and mark the span as failed.
+// Errors recorded without a highlight trace context are tagged with the span's trace and span IDs.
+// Call it during setup, before highlight.Start; calling it again has no effect.
+func EnableErrorBridge() {
+	bridgeOnce.Do(func() {
+		highlight.OnError(recordOnSpan)
+	})
+}
+
+var bridgeOnce sync.Once
+
+func recordOnSpan(ctx context.Context, e *highlight.BackendErrorObjectInput) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	if sc := span.SpanContext(); sc.IsValid() && e.TraceID == "" {
+		e.TraceID = graphql.String(sc.TraceID().String())
+		e.SpanID = graphql.String(sc.SpanID().String())
+	}
+	span.AddEvent(semconv.ExceptionEventName, trace.WithTimestamp(e.Timestamp), trace.WithAttributes(
+		semconv.ExceptionType(exceptionType(e)),
+		semconv.ExceptionMessage(string(e.Event)),
+		semconv.ExceptionStacktrace(string(e.StackTrace)),
+		consumedKey.Bool(true),
+	))
+	span.SetStatus(codes.Error, string(e.Event))
+}
+
+// exceptionType is the Go type of the error, or the Type of a *highlight.Exception.
+// e.Type is the error category, e.g. BACKEND.
+func exceptionType(e *highlight.BackendErrorObjectInput) string {
+	if e.ErrorType != "" {
+		return e.ErrorType
+	}
+	return string(e.Type)
+}
@@ -0,0 +1,110 @@
+package otel
+
+import (
+	"context"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// MetricExporter is an sdkmetric.Exporter that records OpenTelemetry metrics with
+// highlight.RecordMetricInput. Each data point becomes one highlight metric tagged with
+// its attributes; histograms are recorded as <name>.count, <name>.sum, <name>.min and <name>.max.
+// Data points carrying the SessionSecureIDKey and RequestIDKey attributes are tied to that
+// session; others need highlight.SetBackendOnlyMode.
+type MetricExporter struct{}
+
+// NewMetricExporter returns a MetricExporter. Register it with a periodic reader:
+//
+//	mp := sdkmetric.NewMeterProvider(
+//		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(highlightotel.NewMetricExporter())),
+//	)
+func NewMetricExporter() *MetricExporter {
+	return &MetricExporter{}
+}
+
+// Temporality implements sdkmetric.Exporter. Highlight stores individual samples, so counters
+// and histograms are exported as deltas. Up-down counters and gauges describe a current level,
+// which only their cumulative value gives.
+func (e *MetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter,
+		sdkmetric.InstrumentKindObservableGauge:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+// Aggregation implements sdkmetric.Exporter
+func (e *MetricExporter) Aggregation(kind sdkmetric.InstrumentKind) aggregation.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Export implements sdkmetric.Exporter
+func (e *MetricExporter) Export(ctx context.Context, rm metricdata.ResourceMetrics) error {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				exportDataPoints(m.Name, data.DataPoints)
+			case metricdata.Gauge[float64]:
+				exportDataPoints(m.Name, data.DataPoints)
+			case metricdata.Sum[int64]:
+				exportDataPoints(m.Name, data.DataPoints)
+			case metricdata.Sum[float64]:
+				exportDataPoints(m.Name, data.DataPoints)
+			case metricdata.Histogram:
+				for _, dp := range data.DataPoints {
+					attrs := dp.Attributes.ToSlice()
+					record(m.Name+".count", float64(dp.Count), dp.Time, attrs)
+					record(m.Name+".sum", dp.Sum, dp.Time, attrs)
+					if min, ok := dp.Min.Value(); ok {
+						record(m.Name+".min", min, dp.Time, attrs)
+					}
+					if max, ok := dp.Max.Value(); ok {
+						record(m.Name+".max", max, dp.Time, attrs)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ForceFlush implements sdkmetric.Exporter. Metrics are handed to the highlight worker,
+// which flushes on its own interval.
+func (e *MetricExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown implements sdkmetric.Exporter
+func (e *MetricExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func exportDataPoints[N int64 | float64](name string, dataPoints []metricdata.DataPoint[N]) {
+	for _, dp := range dataPoints {
+		record(name, float64(dp.Value), dp.Time, dp.Attributes.ToSlice())
+	}
+}
+
+func record(name string, value float64, timestamp time.Time, attrs []attribute.KeyValue) {
+	ctx, converted := contextFromAttributes(context.Background(), attrs)
+	tags := make([]highlight.MetricTag, 0, len(converted))
+	for _, a := range converted {
+		tags = append(tags, highlight.MetricTag{Name: graphql.String(a.Key), Value: graphql.String(a.Value)})
+	}
+	highlight.RecordMetricInput(ctx, highlight.MetricInput{
+		Name:      graphql.String(name),
+		Value:     graphql.Float(value),
+		Timestamp: timestamp.UTC(),
+		Tags:      tags,
+	})
+}
@@ -0,0 +1,54 @@
+// Package otel connects OpenTelemetry instrumentation to highlight.
+//
+// It provides an sdktrace.SpanExporter and an sdkmetric.Exporter that translate
+// OpenTelemetry spans and metrics into highlight spans, metrics and errors, a span processor
+// that tags spans with the highlight session, and a bridge that records errors passed
+// to highlight.ConsumeError as exceptions on the active OpenTelemetry span.
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// attributes carrying the highlight IDs on spans and metric data points
+const (
+	SessionSecureIDKey = attribute.Key(highlight.BaggageSessionSecureID)
+	RequestIDKey       = attribute.Key(highlight.BaggageRequestID)
+)
+
+// consumedKey marks exception events recorded by the error bridge, which were
+// already reported through highlight.ConsumeError and must not be exported again.
+const consumedKey = attribute.Key("highlight.consumed")
+
+// contextFromAttributes builds a context carrying the highlight IDs found in attrs,
+// and returns the remaining attributes converted to highlight attributes.
+func contextFromAttributes(ctx context.Context, attrs []attribute.KeyValue) (context.Context, []highlight.Attribute) {
+	var sessionSecureID, requestID string
+	converted := make([]highlight.Attribute, 0, len(attrs))
+	for _, kv := range attrs {
+		switch kv.Key {
+		case SessionSecureIDKey:
+			sessionSecureID = kv.Value.Emit()
+		case RequestIDKey:
+			requestID = kv.Value.Emit()
+		default:
+			converted = append(converted, highlight.Attribute{Key: string(kv.Key), Value: kv.Value.Emit()})
+		}
+	}
+	if sessionSecureID != "" {
+		ctx = highlight.WithSession(ctx, sessionSecureID, requestID)
+	}
+	return ctx, converted
+}
+
+var (
+	_ sdktrace.SpanExporter  = (*SpanExporter)(nil)
+	_ sdktrace.SpanProcessor = (*SpanProcessor)(nil)
+	_ sdkmetric.Exporter     = (*MetricExporter)(nil)
+)
@@ -0,0 +1,125 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+func TestSpanExporter(t *testing.T) {
+	EnableErrorBridge()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(NewSpanProcessor()),
+		sdktrace.WithSpanProcessor(recorder),
+		sdktrace.WithSyncer(NewSpanExporter()),
+	)
+	tracer := tp.Tracer("test")
+
+	highlight.Start()
+	defer highlight.Stop()
+	before := highlight.Stats()
+
+	ctx := highlight.WithSession(context.Background(), "session", "request")
+	ctx, span := tracer.Start(ctx, "db.query")
+	// reported by the exporter
+	span.RecordError(fmt.Errorf("recorded by otel"))
+	// reported by ConsumeError, and recorded on the span by the bridge
+	highlight.ConsumeError(ctx, fmt.Errorf("consumed by highlight"))
+	span.End()
+
+	after := highlight.Stats()
+	if after.ErrorsEnqueued-before.ErrorsEnqueued != 2 {
+		t.Errorf("wrong number of enqueued errors [%v != %v]", after.ErrorsEnqueued-before.ErrorsEnqueued, 2)
+	}
+	if after.MetricsEnqueued-before.MetricsEnqueued != 1 {
+		t.Errorf("wrong number of enqueued spans [%v != %v]", after.MetricsEnqueued-before.MetricsEnqueued, 1)
+	}
+	ended := recorder.Ended()
+	if len(ended) != 1 || len(ended[0].Events()) != 2 {
+		t.Fatalf("bridge did not record the consumed error on the span")
+	}
+	if ended[0].Attributes()[0].Key != SessionSecureIDKey {
+		t.Errorf("span processor did not tag the span with the session: %v", ended[0].Attributes())
+	}
+}
+
+func TestErrorBridge(t *testing.T) {
+	EnableErrorBridge()
+	EnableErrorBridge()
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	highlight.Start()
+	defer highlight.Stop()
+
+	ctx, span := tracer.Start(highlight.WithSession(context.Background(), "session", "request"), "charge")
+	highlight.ConsumeError(ctx, errors.New("card declined"))
+	span.End()
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || len(ended[0].Events()) != 1 {
+		t.Fatalf("exception not recorded exactly once on the span")
+	}
+	var exceptionType string
+	for _, attr := range ended[0].Events()[0].Attributes {
+		if attr.Key == semconv.ExceptionTypeKey {
+			exceptionType = attr.Value.AsString()
+		}
+	}
+	if exceptionType != "*errors.errorString" {
+		t.Errorf("wrong exception type [%q != %q]", exceptionType, "*errors.errorString")
+	}
+}
+
+func TestMetricExporter(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	meter := mp.Meter("test")
+	counter, _ := meter.Int64Counter("requests")
+	histogram, _ := meter.Float64Histogram("latency")
+
+	ctx := context.Background()
+	counter.Add(ctx, 3, SessionSecureIDKey.String("session"), RequestIDKey.String("request"))
+	histogram.Record(ctx, 0.5, SessionSecureIDKey.String("session"), RequestIDKey.String("request"))
+
+	highlight.Start()
+	defer highlight.Stop()
+	before := highlight.Stats()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("error collecting metrics: %v", err)
+	}
+	if err := NewMetricExporter().Export(ctx, rm); err != nil {
+		t.Fatalf("error exporting metrics: %v", err)
+	}
+	after := highlight.Stats()
+	// the counter, plus count, sum, min and max of the histogram
+	if after.MetricsEnqueued-before.MetricsEnqueued != 5 {
+		t.Errorf("wrong number of enqueued metrics [%v != %v]", after.MetricsEnqueued-before.MetricsEnqueued, 5)
+	}
+}
+
+func TestMetricExporterTemporality(t *testing.T) {
+	e := NewMetricExporter()
+	for kind, expected := range map[sdkmetric.InstrumentKind]metricdata.Temporality{
+		sdkmetric.InstrumentKindCounter:                 metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindHistogram:               metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindObservableCounter:       metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindUpDownCounter:           metricdata.CumulativeTemporality,
+		sdkmetric.InstrumentKindObservableUpDownCounter: metricdata.CumulativeTemporality,
+		sdkmetric.InstrumentKindObservableGauge:         metricdata.CumulativeTemporality,
+	} {
+		if temporality := e.Temporality(kind); temporality != expected {
+			t.Errorf("wrong temporality for instrument kind %v [%v != %v]", kind, temporality, expected)
+		}
+	}
+}
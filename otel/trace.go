@@ -0,0 +1,129 @@
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// SpanExporter is an sdktrace.SpanExporter that records OpenTelemetry spans with
+// highlight.RecordSpan and span events of type "exception" as highlight errors.
+// Spans are tied to a session through the SessionSecureIDKey and RequestIDKey attributes,
+// which NewSpanProcessor sets; spans without them need highlight.SetBackendOnlyMode.
+type SpanExporter struct{}
+
+// NewSpanExporter returns a SpanExporter. Register it with a batching span processor:
+//
+//	tp := sdktrace.NewTracerProvider(
+//		sdktrace.WithSpanProcessor(highlightotel.NewSpanProcessor()),
+//		sdktrace.WithBatcher(highlightotel.NewSpanExporter()),
+//	)
+func NewSpanExporter() *SpanExporter {
+	return &SpanExporter{}
+}
+
+// ExportSpans implements sdktrace.SpanExporter
+func (e *SpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	for _, span := range spans {
+		spanCtx, attrs := contextFromAttributes(context.Background(), span.Attributes())
+		sc := span.SpanContext()
+		spanCtx = highlight.WithTraceContext(spanCtx, highlight.TraceContext{
+			TraceID: sc.TraceID().String(),
+			SpanID:  sc.SpanID().String(),
+			Sampled: sc.IsSampled(),
+		})
+
+		for _, event := range span.Events() {
+			if event.Name != semconv.ExceptionEventName {
+				continue
+			}
+			if exception, ok := exceptionFromEvent(event); ok {
+				highlight.ConsumeError(spanCtx, exception, "source:opentelemetry")
+			}
+		}
+
+		data := highlight.SpanData{
+			Name:       span.Name(),
+			TraceID:    sc.TraceID().String(),
+			SpanID:     sc.SpanID().String(),
+			Start:      span.StartTime(),
+			End:        span.EndTime(),
+			Attributes: append(attrs, highlight.Attr("span.kind", span.SpanKind())),
+		}
+		if parent := span.Parent(); parent.IsValid() {
+			data.ParentSpanID = parent.SpanID().String()
+		}
+		switch span.Status().Code {
+		case codes.Ok:
+			data.Status = highlight.SpanStatusOK
+		case codes.Error:
+			data.Status = highlight.SpanStatusError
+			data.StatusMessage = span.Status().Description
+		default:
+			data.Status = highlight.SpanStatusUnset
+		}
+		highlight.RecordSpan(spanCtx, data)
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter. Spans are handed to the highlight worker,
+// which is stopped with highlight.Stop.
+func (e *SpanExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// exceptionFromEvent converts an "exception" span event, skipping events recorded by the error bridge
+func exceptionFromEvent(event sdktrace.Event) (*highlight.Exception, bool) {
+	exception := &highlight.Exception{Timestamp: event.Time}
+	for _, kv := range event.Attributes {
+		switch kv.Key {
+		case consumedKey:
+			return nil, false
+		case semconv.ExceptionTypeKey:
+			exception.Type = kv.Value.Emit()
+		case semconv.ExceptionMessageKey:
+			exception.Message = kv.Value.Emit()
+		case semconv.ExceptionStacktraceKey:
+			exception.StackTrace = kv.Value.Emit()
+		}
+	}
+	return exception, true
+}
+
+// SpanProcessor is an sdktrace.SpanProcessor that tags every span started from a context
+// carrying highlight IDs with the SessionSecureIDKey and RequestIDKey attributes.
+type SpanProcessor struct{}
+
+// NewSpanProcessor returns a SpanProcessor
+func NewSpanProcessor() *SpanProcessor {
+	return &SpanProcessor{}
+}
+
+// OnStart implements sdktrace.SpanProcessor
+func (p *SpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if session, ok := highlight.SessionFromContext(parent); ok {
+		s.SetAttributes(
+			attribute.String(string(SessionSecureIDKey), session.SecureID),
+			attribute.String(string(RequestIDKey), session.RequestID),
+		)
+	}
+}
+
+// OnEnd implements sdktrace.SpanProcessor
+func (p *SpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+// Shutdown implements sdktrace.SpanProcessor
+func (p *SpanProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush implements sdktrace.SpanProcessor
+func (p *SpanProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
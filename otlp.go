@@ -0,0 +1,434 @@
+package highlight
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ExportProtocol selects the wire protocol used to send errors, metrics and spans
+type ExportProtocol string
+
+const (
+	// ProtocolGraphQL sends data through the GraphQL mutations of the public endpoint. It is the default.
+	ProtocolGraphQL ExportProtocol = "graphql"
+	// ProtocolOTLPHTTPProtobuf sends data to an OTLP/HTTP endpoint as binary protobuf
+	ProtocolOTLPHTTPProtobuf ExportProtocol = "http/protobuf"
+	// ProtocolOTLPHTTPJSON sends data to an OTLP/HTTP endpoint as JSON
+	ProtocolOTLPHTTPJSON ExportProtocol = "http/json"
+)
+
+// instrumentationScope names the SDK in OTLP payloads
+const instrumentationScope = "github.com/highlight-run/highlight-go"
+
+var (
+	otlpEndpoint = "http://localhost:4318"
+	otlpHeaders  map[string]string
+	otlpClient   = &http.Client{Timeout: 10 * time.Second}
+)
+
+// SetExportProtocol selects how errors, metrics and spans are sent. With one of the OTLP
+// protocols, errors and lines recorded with Log are sent as log records, metrics as gauges
+// (or sums for Counter values) and spans as spans to the endpoint set with SetOTLPEndpoint,
+// e.g. an OpenTelemetry Collector.
+func SetExportProtocol(protocol ExportProtocol) {
+	switch protocol {
+	case ProtocolOTLPHTTPProtobuf:
+		requester = otlpRequester{json: false}
+	case ProtocolOTLPHTTPJSON:
+		requester = otlpRequester{json: true}
+	default:
+		requester = graphqlRequester{}
+	}
+}
+
+// SetOTLPEndpoint sets the base URL of the OTLP/HTTP endpoint, e.g. http://localhost:4318.
+// Data is posted to the /v1/logs, /v1/metrics and /v1/traces paths below it.
+func SetOTLPEndpoint(endpoint string) {
+	otlpEndpoint = strings.TrimSuffix(endpoint, "/")
+}
+
+// SetOTLPHeaders sets extra headers, e.g. for authentication, sent with every OTLP request
+func SetOTLPHeaders(headers map[string]string) {
+	otlpHeaders = headers
+}
+
+type otlpRequester struct {
+	json bool
+}
+
+// trigger posts errors, metrics and spans separately. When some of the posts fail, it
+// returns a partialExportError with what is left, so only the failed signals are retried.
+func (o otlpRequester) trigger(errorsInput []*BackendErrorObjectInput, metricsInputs []*MetricInput) error {
+	resource := otlpResource()
+	scope := &commonpb.InstrumentationScope{Name: instrumentationScope}
+	var firstErr error
+	failed := func(err error) bool {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return err != nil
+	}
+	var pendingErrors []*BackendErrorObjectInput
+	var pendingMetrics []*MetricInput
+
+	if len(errorsInput) > 0 {
+		records := make([]*logspb.LogRecord, 0, len(errorsInput))
+		for _, e := range errorsInput {
+			records = append(records, errorToLogRecord(e))
+		}
+		err := o.post("/v1/logs", &collectorlogs.ExportLogsServiceRequest{
+			ResourceLogs: []*logspb.ResourceLogs{{
+				Resource:  resource,
+				ScopeLogs: []*logspb.ScopeLogs{{Scope: scope, LogRecords: records}},
+			}},
+		})
+		if failed(err) {
+			pendingErrors = errorsInput
+		}
+	}
+
+	var metrics []*metricspb.Metric
+	var spans []*tracepb.Span
+	var metricInputs, spanInputs []*MetricInput
+	for _, m := range metricsInputs {
+		if m.Category != nil && *m.Category == spanCategory {
+			spans = append(spans, metricToSpan(m))
+			spanInputs = append(spanInputs, m)
+		} else {
+			metrics = append(metrics, metricToOTLP(m))
+			metricInputs = append(metricInputs, m)
+		}
+	}
+	if len(metrics) > 0 {
+		err := o.post("/v1/metrics", &collectormetrics.ExportMetricsServiceRequest{
+			ResourceMetrics: []*metricspb.ResourceMetrics{{
+				Resource:     resource,
+				ScopeMetrics: []*metricspb.ScopeMetrics{{Scope: scope, Metrics: metrics}},
+			}},
+		})
+		if failed(err) {
+			pendingMetrics = append(pendingMetrics, metricInputs...)
+		}
+	}
+	if len(spans) > 0 {
+		err := o.post("/v1/traces", &collectortrace.ExportTraceServiceRequest{
+			ResourceSpans: []*tracepb.ResourceSpans{{
+				Resource:   resource,
+				ScopeSpans: []*tracepb.ScopeSpans{{Scope: scope, Spans: spans}},
+			}},
+		})
+		if failed(err) {
+			pendingMetrics = append(pendingMetrics, spanInputs...)
+		}
+	}
+	if firstErr != nil {
+		return &partialExportError{err: firstErr, errors: pendingErrors, metrics: pendingMetrics}
+	}
+	return nil
+}
+
+func (o otlpRequester) triggerLogs(logsInput []*LogInput) error {
+	if len(logsInput) == 0 {
+		return nil
+	}
+	records := make([]*logspb.LogRecord, 0, len(logsInput))
+	for _, l := range logsInput {
+		records = append(records, logToLogRecord(l))
+	}
+	return o.post("/v1/logs", &collectorlogs.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource:  otlpResource(),
+			ScopeLogs: []*logspb.ScopeLogs{{Scope: &commonpb.InstrumentationScope{Name: instrumentationScope}, LogRecords: records}},
+		}},
+	})
+}
+
+func (o otlpRequester) post(path string, message proto.Message) error {
+	var body []byte
+	var err error
+	contentType := "application/x-protobuf"
+	if o.json {
+		contentType = "application/json"
+		body, err = marshalOTLPJSON(message)
+	} else {
+		body, err = proto.Marshal(message)
+	}
+	if err != nil {
+		return fmt.Errorf("error encoding otlp payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, otlpEndpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range otlpHeaders {
+		req.Header.Set(k, v)
+	}
+	resp, err := otlpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp endpoint %s returned %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// marshalOTLPJSON encodes message following the OTLP/HTTP JSON rules, which differ from
+// the canonical protobuf JSON mapping: enums are integers and trace and span IDs are
+// hex rather than base64 encoded.
+func marshalOTLPJSON(message proto.Message) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	hexEncodeIDs(doc)
+	return json.Marshal(doc)
+}
+
+func hexEncodeIDs(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			switch k {
+			case "traceId", "spanId", "parentSpanId":
+				if s, ok := child.(string); ok {
+					if raw, err := base64.StdEncoding.DecodeString(s); err == nil {
+						v[k] = hex.EncodeToString(raw)
+					}
+				}
+			default:
+				hexEncodeIDs(child)
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			hexEncodeIDs(child)
+		}
+	}
+}
+
+func otlpResource() *resourcepb.Resource {
+	name := serviceName
+	if name == "" {
+		name = "unknown_service"
+	}
+	attrs := []*commonpb.KeyValue{stringAttribute("service.name", name)}
+	if environment != "" {
+		attrs = append(attrs, stringAttribute("deployment.environment", environment))
+	}
+	if hostname != "" {
+		attrs = append(attrs, stringAttribute("host.name", hostname))
+	}
+	return &resourcepb.Resource{Attributes: attrs}
+}
+
+func errorToLogRecord(e *BackendErrorObjectInput) *logspb.LogRecord {
+	attrs := []*commonpb.KeyValue{
+		stringAttribute("exception.type", errorType(e)),
+		stringAttribute("exception.message", string(e.Event)),
+		stringAttribute("exception.stacktrace", string(e.StackTrace)),
+	}
+	attrs = appendSessionAttributes(attrs, string(e.SessionSecureID), string(e.RequestID), bool(e.SessionAbsent))
+	if e.URL != "" {
+		attrs = append(attrs, stringAttribute("url.full", string(e.URL)))
+	}
+	if e.Source != "" {
+		attrs = append(attrs, stringAttribute("highlight.source", string(e.Source)))
+	}
+	if e.Payload != nil {
+		attrs = append(attrs, stringAttribute("highlight.payload", string(*e.Payload)))
+	}
+	if e.Capture != nil {
+		if b, err := json.Marshal(e.Capture); err == nil {
+			attrs = append(attrs, stringAttribute("highlight.capture", string(b)))
+		}
+	}
+	if len(e.Breadcrumbs) > 0 {
+		if b, err := json.Marshal(e.Breadcrumbs); err == nil {
+			attrs = append(attrs, stringAttribute("highlight.breadcrumbs", string(b)))
+		}
+	}
+	return &logspb.LogRecord{
+		TimeUnixNano:         uint64(e.Timestamp.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+		SeverityText:         "ERROR",
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: string(e.Event)}},
+		Attributes:           attrs,
+		TraceId:              decodeHexID(string(e.TraceID), 16),
+		SpanId:               decodeHexID(string(e.SpanID), 8),
+	}
+}
+
+// errorType is the exception.type of e, falling back to its category when the type is unknown
+func errorType(e *BackendErrorObjectInput) string {
+	if e.ErrorType != "" {
+		return e.ErrorType
+	}
+	return string(e.Type)
+}
+
+func logToLogRecord(l *LogInput) *logspb.LogRecord {
+	attrs := appendSessionAttributes(tagAttributes(l.Attributes), string(l.SessionSecureID), string(l.RequestID), bool(l.SessionAbsent))
+	severity := logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	switch string(l.Level) {
+	case LevelDebug.String():
+		severity = logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case LevelWarn.String():
+		severity = logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case LevelError.String():
+		severity = logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	}
+	return &logspb.LogRecord{
+		TimeUnixNano:         uint64(l.Timestamp.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severity,
+		SeverityText:         string(l.Level),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: string(l.Message)}},
+		Attributes:           attrs,
+		TraceId:              decodeHexID(string(l.TraceID), 16),
+		SpanId:               decodeHexID(string(l.SpanID), 8),
+	}
+}
+
+// metricToOTLP converts a metric into a gauge, or a delta sum if it was recorded by a Counter.
+// Delta sums start with the flush window the metric was exported in.
+func metricToOTLP(m *MetricInput) *metricspb.Metric {
+	attrs := appendGroupAttribute(tagAttributes(m.Tags), m.Group)
+	attrs = appendSessionAttributes(attrs, string(m.SessionSecureID), string(m.RequestID), bool(m.SessionAbsent))
+	if m.Category != nil {
+		attrs = append(attrs, stringAttribute("highlight.category", string(*m.Category)))
+	}
+	if m.TraceID != "" {
+		attrs = append(attrs, stringAttribute("trace_id", string(m.TraceID)), stringAttribute("span_id", string(m.SpanID)))
+	}
+	dataPoints := []*metricspb.NumberDataPoint{{
+		TimeUnixNano: uint64(m.Timestamp.UnixNano()),
+		Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: float64(m.Value)},
+		Attributes:   attrs,
+	}}
+	metric := &metricspb.Metric{Name: string(m.Name), Unit: string(m.Unit)}
+	if MetricType(m.Type) == MetricTypeCounter {
+		start := flushedWindowStart
+		if start.IsZero() || start.After(m.Timestamp) {
+			start = m.Timestamp
+		}
+		dataPoints[0].StartTimeUnixNano = uint64(start.UnixNano())
+		metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints:             dataPoints,
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			IsMonotonic:            true,
+		}}
+	} else {
+		metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints}}
+	}
+	return metric
+}
+
+// metricToSpan converts a SPAN metric recorded by RecordSpan back into a span
+func metricToSpan(m *MetricInput) *tracepb.Span {
+	span := &tracepb.Span{
+		TraceId:           decodeHexID(string(m.TraceID), 16),
+		SpanId:            decodeHexID(string(m.SpanID), 8),
+		ParentSpanId:      decodeHexID(string(m.ParentSpanID), 8),
+		Name:              string(m.Name),
+		Kind:              tracepb.Span_SPAN_KIND_INTERNAL,
+		StartTimeUnixNano: uint64(m.Timestamp.UnixNano()),
+		EndTimeUnixNano:   uint64(m.Timestamp.Add(time.Duration(float64(m.Value) * float64(time.Second))).UnixNano()),
+		Status:            &tracepb.Status{},
+	}
+	var tags []MetricTag
+	for _, tag := range m.Tags {
+		switch tag.Name {
+		case "status":
+			switch SpanStatus(tag.Value) {
+			case SpanStatusOK:
+				span.Status.Code = tracepb.Status_STATUS_CODE_OK
+			case SpanStatusError:
+				span.Status.Code = tracepb.Status_STATUS_CODE_ERROR
+			}
+		case "status_message":
+			span.Status.Message = string(tag.Value)
+		default:
+			tags = append(tags, tag)
+		}
+	}
+	attrs := appendGroupAttribute(tagAttributes(tags), m.Group)
+	span.Attributes = appendSessionAttributes(attrs, string(m.SessionSecureID), string(m.RequestID), bool(m.SessionAbsent))
+	return span
+}
+
+// appendGroupAttribute adds the metric group, which is the request ID unless set with
+// MetricOptions or RecordProcessMetric, as the highlight.group attribute
+func appendGroupAttribute(attrs []*commonpb.KeyValue, group *graphql.String) []*commonpb.KeyValue {
+	if group != nil && *group != "" {
+		attrs = append(attrs, stringAttribute("highlight.group", string(*group)))
+	}
+	return attrs
+}
+
+func appendSessionAttributes(attrs []*commonpb.KeyValue, sessionSecureID, requestID string, sessionAbsent bool) []*commonpb.KeyValue {
+	if sessionSecureID != "" {
+		attrs = append(attrs, stringAttribute(BaggageSessionSecureID, sessionSecureID))
+	}
+	if requestID != "" {
+		attrs = append(attrs, stringAttribute(BaggageRequestID, requestID))
+	}
+	if sessionAbsent {
+		attrs = append(attrs, &commonpb.KeyValue{Key: "highlight.session_absent", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}}})
+	}
+	return attrs
+}
+
+func tagAttributes(tags []MetricTag) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(tags))
+	for _, tag := range tags {
+		attrs = append(attrs, stringAttribute(string(tag.Name), string(tag.Value)))
+	}
+	return attrs
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// decodeHexID decodes a hex trace or span ID, returning nil if it is missing or malformed
+func decodeHexID(id string, size int) []byte {
+	if len(id) != 2*size {
+		return nil
+	}
+	b, err := hex.DecodeString(id)
+	if err != nil {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,241 @@
+package highlight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hasura/go-graphql-client"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collectorStandIn records the bodies posted to each OTLP/HTTP path
+type collectorStandIn struct {
+	mu          sync.Mutex
+	bodies      map[string][]byte
+	contentType string
+}
+
+func (c *collectorStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bodies[r.URL.Path] = body
+	c.contentType = r.Header.Get("Content-Type")
+}
+
+func TestOTLPProtobuf(t *testing.T) {
+	collector := &collectorStandIn{bodies: map[string][]byte{}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	SetOTLPEndpoint(server.URL)
+	SetExportProtocol(ProtocolOTLPHTTPProtobuf)
+	defer SetExportProtocol(ProtocolGraphQL)
+
+	Start()
+	ctx := WithSession(context.Background(), "session", "request")
+	ConsumeError(ctx, fmt.Errorf("error here"))
+	RecordMetric(ctx, "myMetric", 1.5)
+	_, span := StartSpan(ctx, "db.query")
+	span.RecordError(fmt.Errorf("query failed"))
+	span.End()
+	if err := requester.trigger(flush()); err != nil {
+		t.Fatalf("error exporting: %v", err)
+	}
+	Stop()
+
+	if collector.contentType != "application/x-protobuf" {
+		t.Errorf("unexpected content type %q", collector.contentType)
+	}
+	var logs collectorlogs.ExportLogsServiceRequest
+	if err := proto.Unmarshal(collector.bodies["/v1/logs"], &logs); err != nil {
+		t.Fatalf("error decoding logs: %v", err)
+	}
+	records := logs.ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 2 || records[0].Body.GetStringValue() != "error here" {
+		t.Errorf("unexpected log records: %v", records)
+	}
+	if len(records[1].TraceId) != 16 || len(records[1].SpanId) != 8 {
+		t.Errorf("error raised in a span is missing its trace: %v", records[1])
+	}
+
+	var metrics collectormetrics.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(collector.bodies["/v1/metrics"], &metrics); err != nil {
+		t.Fatalf("error decoding metrics: %v", err)
+	}
+	m := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(m) != 1 || m[0].Name != "myMetric" || m[0].GetGauge().DataPoints[0].GetAsDouble() != 1.5 {
+		t.Errorf("unexpected metrics: %v", m)
+	}
+
+	var traces collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(collector.bodies["/v1/traces"], &traces); err != nil {
+		t.Fatalf("error decoding traces: %v", err)
+	}
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 || spans[0].Name != "db.query" || spans[0].Status.Message != "query failed" {
+		t.Errorf("unexpected spans: %v", spans)
+	}
+}
+
+func TestOTLPJSON(t *testing.T) {
+	collector := &collectorStandIn{bodies: map[string][]byte{}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	SetOTLPEndpoint(server.URL + "/")
+	SetExportProtocol(ProtocolOTLPHTTPJSON)
+	defer SetExportProtocol(ProtocolGraphQL)
+
+	Start()
+	_, span := StartSpan(WithSession(context.Background(), "session", "request"), "db.query")
+	span.End()
+	if err := requester.trigger(flush()); err != nil {
+		t.Fatalf("error exporting: %v", err)
+	}
+	Stop()
+
+	if collector.contentType != "application/json" {
+		t.Errorf("unexpected content type %q", collector.contentType)
+	}
+	var doc struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(collector.bodies["/v1/traces"], &doc); err != nil {
+		t.Fatalf("error decoding traces: %v", err)
+	}
+	s := doc.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.TraceID != span.TraceID() || s.SpanID != span.SpanID() || s.Kind != 1 {
+		t.Errorf("span not encoded with hex IDs and numeric enums: %+v", s)
+	}
+}
+
+func TestOTLPErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	SetOTLPEndpoint(server.URL)
+	cat := graphql.String(metricCategory)
+	err := otlpRequester{}.trigger(nil, []*MetricInput{{Name: "myMetric", Category: &cat}})
+	if err == nil || !strings.Contains(err.Error(), "bad payload") {
+		t.Errorf("expected the endpoint's error to be returned, got %v", err)
+	}
+}
+
+func TestOTLPPartialRetry(t *testing.T) {
+	var mu sync.Mutex
+	posts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		posts[r.URL.Path]++
+		// the collector rejects the first batch of metrics only
+		if r.URL.Path == "/v1/metrics" && posts[r.URL.Path] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	SetOTLPEndpoint(server.URL)
+	SetExportProtocol(ProtocolOTLPHTTPProtobuf)
+	defer SetExportProtocol(ProtocolGraphQL)
+
+	Start()
+	ctx := WithSession(context.Background(), "session", "request")
+	ConsumeError(ctx, fmt.Errorf("error here"))
+	RecordMetric(ctx, "myMetric", 1.5)
+	_, span := StartSpan(ctx, "db.query")
+	span.End()
+	export(flush())
+	Stop()
+
+	expected := map[string]int{"/v1/logs": 1, "/v1/metrics": 2, "/v1/traces": 1}
+	for path, count := range expected {
+		if posts[path] != count {
+			t.Errorf("wrong number of posts to %s [%v != %v]", path, posts[path], count)
+		}
+	}
+}
+
+func TestOTLPExceptionType(t *testing.T) {
+	requester = mockRequester{}
+	Start()
+	ctx := WithSession(context.Background(), "session", "request")
+	ConsumeError(ctx, &Exception{Type: "ValueError", Message: "bad value"})
+	ConsumeError(ctx, fmt.Errorf("wrapped: %w", io.EOF))
+	errs, _ := flush()
+	Stop()
+
+	for i, expected := range []string{"ValueError", "*fmt.wrapError"} {
+		var exceptionType string
+		for _, a := range errorToLogRecord(errs[i]).Attributes {
+			if a.Key == "exception.type" {
+				exceptionType = a.Value.GetStringValue()
+			}
+		}
+		if exceptionType != expected {
+			t.Errorf("wrong exception.type [%q != %q]", exceptionType, expected)
+		}
+	}
+}
+
+func TestOTLPMetricAttributes(t *testing.T) {
+	collector := &collectorStandIn{bodies: map[string][]byte{}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	SetOTLPEndpoint(server.URL)
+	SetExportProtocol(ProtocolOTLPHTTPProtobuf)
+	defer SetExportProtocol(ProtocolGraphQL)
+
+	Start()
+	ctx := WithSession(context.Background(), "session", "request")
+	flush()
+	NewCounter("jobs.processed", &MetricOptions{Group: "workers"}).Add(ctx, 1)
+	RecordProcessMetric(MetricInput{Name: "pool.size", Value: 4})
+	if err := requester.trigger(flush()); err != nil {
+		t.Fatalf("error exporting: %v", err)
+	}
+	Stop()
+
+	var metrics collectormetrics.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(collector.bodies["/v1/metrics"], &metrics); err != nil {
+		t.Fatalf("error decoding metrics: %v", err)
+	}
+	attributes := map[string]map[string]string{}
+	for _, m := range metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		attrs := map[string]string{}
+		for _, p := range append(m.GetSum().GetDataPoints(), m.GetGauge().GetDataPoints()...) {
+			for _, a := range p.Attributes {
+				attrs[a.Key] = a.Value.GetStringValue()
+			}
+		}
+		attributes[m.Name] = attrs
+		if sum := m.GetSum(); sum != nil {
+			if p := sum.DataPoints[0]; p.StartTimeUnixNano == 0 || p.StartTimeUnixNano > p.TimeUnixNano {
+				t.Errorf("delta sum without the start of its window: %v", p)
+			}
+		}
+	}
+	if a := attributes["jobs.processed"]; a["highlight.group"] != "workers" || a[BaggageRequestID] != "request" {
+		t.Errorf("group or request ID mislabeled on a counter: %v", a)
+	}
+	if a := attributes["pool.size"]; a["highlight.group"] != hostname || a[BaggageRequestID] != "" {
+		t.Errorf("process metric group exported as a request ID: %v", a)
+	}
+}
@@ -0,0 +1,29 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// ConsumePanic reports a value recovered from a panic with ConsumeError, along with the stack
+// of the panicking goroutine. Call it from the deferred function that recovered:
+//
+//	defer func() {
+//		if p := recover(); p != nil {
+//			highlight.ConsumePanic(ctx, p)
+//		}
+//	}()
+func ConsumePanic(ctx context.Context, recovered interface{}, tags ...string) {
+	message := fmt.Sprintf("%v", recovered)
+	if err, ok := recovered.(error); ok {
+		message = err.Error()
+	}
+	ConsumeError(ctx, &Exception{
+		Type:       "panic",
+		Message:    message,
+		StackTrace: string(debug.Stack()),
+		Timestamp:  time.Now(),
+	}, append([]string{"source:panic"}, tags...)...)
+}
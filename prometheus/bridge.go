@@ -0,0 +1,212 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+// Options configures a Bridge
+type Options struct {
+	// Interval between gathers in Run. Defaults to 15 seconds.
+	Interval time.Duration
+	// Filter selects the metric families forwarded by name. All families are forwarded if nil.
+	Filter func(name string) bool
+	// Category of the recorded metrics. Defaults to PROMETHEUS.
+	Category string
+}
+
+// Bridge periodically gathers Prometheus metrics and records them with
+// highlight.RecordProcessMetric, using labels as tags. Counters are recorded as the increase
+// since the previous gather; gauges and untyped metrics as they are; histograms and summaries
+// as <name>.count and <name>.sum increases, plus <name>.bucket increases tagged with "le"
+// for histograms and <name>.p50-style quantiles for summaries.
+type Bridge struct {
+	gatherer prometheus.Gatherer
+	opts     Options
+
+	mu       sync.Mutex
+	previous map[string]float64
+	gathered bool
+}
+
+// NewBridge returns a Bridge gathering from g, e.g. prometheus.DefaultGatherer
+// use as follows:
+//
+// import highlightprometheus "github.com/highlight-run/highlight-go/prometheus"
+// ...
+// bridge := highlightprometheus.NewBridge(prometheus.DefaultGatherer, nil)
+// go bridge.Run(ctx)
+//
+// opts may be nil.
+func NewBridge(g prometheus.Gatherer, opts *Options) *Bridge {
+	b := &Bridge{gatherer: g, previous: map[string]float64{}}
+	if opts != nil {
+		b.opts = *opts
+	}
+	if b.opts.Interval <= 0 {
+		b.opts.Interval = 15 * time.Second
+	}
+	if b.opts.Category == "" {
+		b.opts.Category = "PROMETHEUS"
+	}
+	return b
+}
+
+// NewEndpointBridge returns a Bridge gathering by scraping the text exposition format
+// served at url, e.g. http://localhost:9090/metrics. opts may be nil.
+func NewEndpointBridge(url string, opts *Options) *Bridge {
+	return NewBridge(endpointGatherer{url: url, client: &http.Client{Timeout: 10 * time.Second}}, opts)
+}
+
+// Run gathers every interval until ctx is done
+func (b *Bridge) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = b.Collect()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Collect gathers once and records the metrics. Families gathered without error
+// are recorded even if gathering others failed; the error is returned.
+func (b *Bridge) Collect() error {
+	families, err := b.gatherer.Gather()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, family := range families {
+		if b.opts.Filter != nil && !b.opts.Filter(family.GetName()) {
+			continue
+		}
+		b.recordFamily(family)
+	}
+	b.gathered = true
+	return err
+}
+
+func (b *Bridge) recordFamily(family *dto.MetricFamily) {
+	name := family.GetName()
+	for _, m := range family.GetMetric() {
+		tags := labelTags(m.GetLabel())
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			b.recordDelta(name, m.GetCounter().GetValue(), tags)
+		case dto.MetricType_GAUGE:
+			b.record(name, m.GetGauge().GetValue(), highlight.MetricTypeGauge, tags)
+		case dto.MetricType_UNTYPED:
+			b.record(name, m.GetUntyped().GetValue(), highlight.MetricTypeGauge, tags)
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			b.recordDelta(name+".count", float64(h.GetSampleCount()), tags)
+			b.recordDelta(name+".sum", h.GetSampleSum(), tags)
+			for _, bucket := range h.GetBucket() {
+				// the +Inf bucket, present when parsing text, repeats the count
+				if math.IsInf(bucket.GetUpperBound(), 1) {
+					continue
+				}
+				le := highlight.MetricTag{Name: "le", Value: graphql.String(strconv.FormatFloat(bucket.GetUpperBound(), 'f', -1, 64))}
+				b.recordDelta(name+".bucket", float64(bucket.GetCumulativeCount()), append(tags, le))
+			}
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			b.recordDelta(name+".count", float64(s.GetSampleCount()), tags)
+			b.recordDelta(name+".sum", s.GetSampleSum(), tags)
+			for _, q := range s.GetQuantile() {
+				suffix := ".p" + strings.ReplaceAll(strconv.FormatFloat(q.GetQuantile()*100, 'f', -1, 64), ".", "_")
+				b.record(name+suffix, q.GetValue(), highlight.MetricTypeGauge, tags)
+			}
+		}
+	}
+}
+
+// recordDelta records the increase of a cumulative value since the previous gather.
+// The first gather only remembers the values, since they accumulated before the bridge
+// started; series appearing later count from zero, and a reset records the new value.
+func (b *Bridge) recordDelta(name string, value float64, tags []highlight.MetricTag) {
+	key := seriesKey(name, tags)
+	previous, seen := b.previous[key]
+	b.previous[key] = value
+	if !seen && !b.gathered {
+		return
+	}
+	delta := value - previous
+	if delta < 0 {
+		delta = value
+	}
+	b.record(name, delta, highlight.MetricTypeCounter, tags)
+}
+
+func (b *Bridge) record(name string, value float64, metricType highlight.MetricType, tags []highlight.MetricTag) {
+	category := graphql.String(b.opts.Category)
+	highlight.RecordProcessMetric(highlight.MetricInput{
+		Name:     graphql.String(name),
+		Value:    graphql.Float(value),
+		Category: &category,
+		Type:     graphql.String(metricType),
+		Tags:     tags,
+	})
+}
+
+func labelTags(labels []*dto.LabelPair) []highlight.MetricTag {
+	tags := make([]highlight.MetricTag, 0, len(labels)+1)
+	for _, l := range labels {
+		tags = append(tags, highlight.MetricTag{Name: graphql.String(l.GetName()), Value: graphql.String(l.GetValue())})
+	}
+	return tags
+}
+
+func seriesKey(name string, tags []highlight.MetricTag) string {
+	parts := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		parts = append(parts, string(tag.Name)+"="+string(tag.Value))
+	}
+	sort.Strings(parts)
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
+
+// endpointGatherer gathers by parsing a text exposition endpoint
+type endpointGatherer struct {
+	url    string
+	client *http.Client
+}
+
+// Gather implements prometheus.Gatherer
+func (e endpointGatherer) Gather() ([]*dto.MetricFamily, error) {
+	resp, err := e.client.Get(e.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics endpoint %s returned %s", e.url, resp.Status)
+	}
+	var parser expfmt.TextParser
+	byName, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	families := make([]*dto.MetricFamily, 0, len(byName))
+	for _, family := range byName {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, nil
+}
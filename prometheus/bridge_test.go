@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/highlight-run/highlight-go"
+)
+
+func TestBridge(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"route"})
+	inflight := prometheus.NewGauge(prometheus.GaugeOpts{Name: "inflight"})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency_seconds", Buckets: []float64{0.1, 1}})
+	ignored := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_ignored"})
+	registry.MustRegister(requests, inflight, latency, ignored)
+
+	highlight.Start()
+	defer highlight.Stop()
+	filter := func(name string) bool { return !strings.HasPrefix(name, "go_") }
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	for _, bridge := range []*Bridge{
+		NewBridge(registry, &Options{Filter: filter}),
+		NewEndpointBridge(server.URL, &Options{Filter: filter}),
+	} {
+		before := highlight.Stats()
+		if err := bridge.Collect(); err != nil {
+			t.Fatalf("error collecting: %v", err)
+		}
+		middle := highlight.Stats()
+		// only the gauge is recorded on the first gather
+		if middle.MetricsEnqueued-before.MetricsEnqueued != 1 {
+			t.Errorf("wrong number of metrics on the first gather [%v != %v]", middle.MetricsEnqueued-before.MetricsEnqueued, 1)
+		}
+		requests.WithLabelValues("/").Inc()
+		latency.Observe(0.5)
+		if err := bridge.Collect(); err != nil {
+			t.Fatalf("error collecting: %v", err)
+		}
+		after := highlight.Stats()
+		// the counter, the gauge, and the count, sum and two buckets of the histogram
+		if after.MetricsEnqueued-middle.MetricsEnqueued != 6 {
+			t.Errorf("wrong number of metrics on the second gather [%v != %v]", after.MetricsEnqueued-middle.MetricsEnqueued, 6)
+		}
+	}
+}
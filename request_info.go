@@ -0,0 +1,127 @@
+package highlight
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const requestInfoKey = Highlight + "RequestInfo"
+
+// redactedQueryValue replaces the values of query parameters missing from the allowlist
+const redactedQueryValue = "REDACTED"
+
+var queryAllowlist = map[string]bool{}
+
+// SetQueryAllowlist sets the query parameters whose values are kept in the URL of errors,
+// and in the URLs of outgoing requests in breadcrumbs and Transport errors.
+// The values of all other parameters are replaced with REDACTED, since query strings often
+// carry tokens or personal data. By default no values are kept.
+func SetQueryAllowlist(params ...string) {
+	allowlist := make(map[string]bool, len(params))
+	for _, p := range params {
+		allowlist[p] = true
+	}
+	queryAllowlist = allowlist
+}
+
+// RequestInfo describes the request that a context belongs to
+type RequestInfo struct {
+	Method string
+	// URL is the requested URL, with the query values scrubbed (see SetQueryAllowlist)
+	URL string
+	// Route is the route template the request matched, e.g. /users/{id}, if known
+	Route      string
+	RemoteAddr string
+}
+
+// RequestInfoFromContext returns the request info stored by InterceptRequest and WithRoute
+func RequestInfoFromContext(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey).(RequestInfo)
+	return info, ok
+}
+
+// WithRoute returns a copy of ctx carrying the route template a request matched, e.g.
+// /users/{id}. Errors consumed with the returned context use it as their source, so they
+// group by endpoint rather than by path. The router middlewares call it for you.
+func WithRoute(ctx context.Context, route string) context.Context {
+	info, _ := RequestInfoFromContext(ctx)
+	info.Route = route
+	return withRequestInfo(ctx, info)
+}
+
+// RouteFromContext returns the route template carried by ctx, if any
+func RouteFromContext(ctx context.Context) (string, bool) {
+	info, ok := RequestInfoFromContext(ctx)
+	if !ok || info.Route == "" {
+		return "", false
+	}
+	return info.Route, true
+}
+
+func withRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey, info)
+}
+
+func newRequestInfo(r *http.Request) RequestInfo {
+	return RequestInfo{
+		Method:     r.Method,
+		URL:        scrubbedURL(r),
+		RemoteAddr: r.RemoteAddr,
+	}
+}
+
+// source describes the endpoint, e.g. "GET /users/{id}", falling back to the path
+// when no route is known
+func (i RequestInfo) source() string {
+	endpoint := i.Route
+	if endpoint == "" && i.URL != "" {
+		if u, err := url.Parse(i.URL); err == nil {
+			endpoint = u.Path
+		}
+	}
+	return strings.TrimSpace(i.Method + " " + endpoint)
+}
+
+// scrubbedURL rebuilds the absolute URL of r, without credentials or fragment,
+// redacting the values of query parameters missing from the allowlist
+func scrubbedURL(r *http.Request) string {
+	if r.URL == nil {
+		return ""
+	}
+	u := *r.URL
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if r.Host != "" {
+		u.Host = r.Host
+	}
+	return scrubURL(&u)
+}
+
+// scrubURL formats u without credentials or fragment, redacting the values of query
+// parameters missing from the allowlist (see SetQueryAllowlist)
+func scrubURL(u *url.URL) string {
+	scrubbed := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   u.Path,
+	}
+	if u.RawQuery != "" {
+		var params []string
+		for _, param := range strings.Split(u.RawQuery, "&") {
+			if param == "" {
+				continue
+			}
+			key, _, _ := strings.Cut(param, "=")
+			if name, err := url.QueryUnescape(key); err != nil || !queryAllowlist[name] {
+				param = key + "=" + redactedQueryValue
+			}
+			params = append(params, param)
+		}
+		scrubbed.RawQuery = strings.Join(params, "&")
+	}
+	return scrubbed.String()
+}
@@ -0,0 +1,42 @@
+package highlight
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestInfo(t *testing.T) {
+	requester = mockRequester{}
+	SetQueryAllowlist("page")
+	defer SetQueryAllowlist()
+	Start()
+	defer Stop()
+
+	r := httptest.NewRequest("GET", "https://api.example.com/users/123?page=2&token=secret&page=3", nil)
+	r.Header.Set("X-Highlight-Request", "session/request")
+	r.RemoteAddr = "10.0.0.1:1234"
+	ctx := InterceptRequest(r)
+
+	info, ok := RequestInfoFromContext(ctx)
+	if !ok {
+		t.Fatalf("request info not stored")
+	}
+	expectedURL := "https://api.example.com/users/123?page=2&token=REDACTED&page=3"
+	if info.Method != "GET" || info.URL != expectedURL || info.RemoteAddr != "10.0.0.1:1234" {
+		t.Errorf("wrong request info: %+v", info)
+	}
+
+	ConsumeError(ctx, fmt.Errorf("before routing"))
+	ConsumeError(WithRoute(ctx, "/users/{id}"), fmt.Errorf("after routing"))
+	errs, _ := flush()
+	if len(errs) != 2 {
+		t.Fatalf("flush returned the wrong number of errors [%v != %v]", len(errs), 2)
+	}
+	if string(errs[0].URL) != expectedURL || errs[0].Source != "GET /users/123" {
+		t.Errorf("wrong URL or source without a route: %v %v", errs[0].URL, errs[0].Source)
+	}
+	if string(errs[1].URL) != expectedURL || errs[1].Source != "GET /users/{id}" {
+		t.Errorf("wrong URL or source with a route: %v %v", errs[1].URL, errs[1].Source)
+	}
+}
@@ -0,0 +1,208 @@
+package highlight
+
+import (
+	"math"
+	"runtime/metrics"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// runtimeCategory is the metric category of runtime and process metrics
+const runtimeCategory = "RUNTIME"
+
+var runtimeMetricsInterval time.Duration
+
+// SetRuntimeMetricsInterval enables sampling Go runtime and process metrics every interval:
+// goroutines, heap and total memory, GC cycles and pause quantiles, scheduler latency
+// quantiles, and process CPU time and peak RSS. They are recorded without a session,
+// in the RUNTIME category, tagged with the hostname and service name.
+// 0, the default, disables them. Call it before Start.
+func SetRuntimeMetricsInterval(interval time.Duration) {
+	runtimeMetricsInterval = interval
+}
+
+// runtimeQuantiles are exported for the runtime's latency histograms
+var runtimeQuantiles = []float64{0.5, 0.9, 0.99}
+
+// runtimeGauges are runtime/metrics samples recorded as they are
+var runtimeGauges = map[string]struct {
+	name string
+	unit string
+}{
+	"/sched/goroutines:goroutines":       {"runtime.goroutines", ""},
+	"/memory/classes/heap/objects:bytes": {"runtime.heap.inuse", "By"},
+	"/memory/classes/total:bytes":        {"runtime.memory.total", "By"},
+	"/gc/heap/goal:bytes":                {"runtime.gc.heap_goal", "By"},
+}
+
+// runtimeCounters are cumulative runtime/metrics samples recorded as the increase since the last sample
+var runtimeCounters = map[string]string{
+	"/gc/cycles/total:gc-cycles": "runtime.gc.cycles",
+}
+
+// runtimeHistograms are cumulative runtime/metrics histograms recorded as quantiles of the last interval
+var runtimeHistograms = map[string]string{
+	"/gc/pauses:seconds":       "runtime.gc.pause",
+	"/sched/latencies:seconds": "runtime.sched.latency",
+}
+
+// runtimeCollector samples runtime/metrics, remembering cumulative values to record deltas
+type runtimeCollector struct {
+	samples     []metrics.Sample
+	counters    map[string]uint64
+	histograms  map[string][]uint64
+	processCPU  map[string]float64
+	initialized bool
+}
+
+func newRuntimeCollector() *runtimeCollector {
+	c := &runtimeCollector{
+		counters:   map[string]uint64{},
+		histograms: map[string][]uint64{},
+		processCPU: map[string]float64{},
+	}
+	supported := map[string]bool{}
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+	for _, names := range []map[string]string{runtimeCounters, runtimeHistograms} {
+		for key := range names {
+			if supported[key] {
+				c.samples = append(c.samples, metrics.Sample{Name: key})
+			}
+		}
+	}
+	for key := range runtimeGauges {
+		if supported[key] {
+			c.samples = append(c.samples, metrics.Sample{Name: key})
+		}
+	}
+	return c
+}
+
+// collect records a sample of every runtime and process metric. The first call
+// only records gauges, since deltas need a previous sample.
+func (c *runtimeCollector) collect() {
+	metrics.Read(c.samples)
+	for _, sample := range c.samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			value := sample.Value.Uint64()
+			if gauge, ok := runtimeGauges[sample.Name]; ok {
+				recordRuntimeMetric(gauge.name, float64(value), MetricTypeGauge, gauge.unit)
+			} else if name, ok := runtimeCounters[sample.Name]; ok {
+				if c.initialized {
+					recordRuntimeMetric(name, float64(value-c.counters[sample.Name]), MetricTypeCounter, "")
+				}
+				c.counters[sample.Name] = value
+			}
+		case metrics.KindFloat64Histogram:
+			name, ok := runtimeHistograms[sample.Name]
+			if !ok {
+				continue
+			}
+			h := sample.Value.Float64Histogram()
+			previous := c.histograms[sample.Name]
+			delta := make([]uint64, len(h.Counts))
+			for i, count := range h.Counts {
+				delta[i] = count
+				if i < len(previous) {
+					delta[i] -= previous[i]
+				}
+			}
+			c.histograms[sample.Name] = append([]uint64(nil), h.Counts...)
+			if !c.initialized {
+				continue
+			}
+			for _, q := range runtimeQuantiles {
+				if value, ok := histogramQuantile(delta, h.Buckets, q); ok {
+					recordRuntimeMetric(name+".p"+quantileName(q), value, MetricTypeTimer, "s")
+				}
+			}
+		}
+	}
+	c.collectProcess()
+	c.initialized = true
+}
+
+// recordCPU records the increase of a cumulative CPU time since the last sample
+func (c *runtimeCollector) recordCPU(name string, seconds float64) {
+	if c.initialized {
+		recordRuntimeMetric(name, seconds-c.processCPU[name], MetricTypeCounter, "s")
+	}
+	c.processCPU[name] = seconds
+}
+
+// histogramQuantile estimates the q-quantile of a histogram from the upper bounds of its
+// buckets, falling back to the lower bound for the unbounded last bucket.
+// ok is false if the histogram is empty.
+func histogramQuantile(counts []uint64, buckets []float64, q float64) (value float64, ok bool) {
+	var total uint64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return 0, false
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, count := range counts {
+		seen += count
+		if seen >= rank {
+			// bucket i spans buckets[i] to buckets[i+1]
+			if upper := buckets[i+1]; !math.IsInf(upper, 1) {
+				return upper, true
+			}
+			return buckets[i], true
+		}
+	}
+	return buckets[len(buckets)-1], true
+}
+
+// recordRuntimeMetric records a runtime or process sample with RecordProcessMetric
+func recordRuntimeMetric(name string, value float64, metricType MetricType, unit string) {
+	category := graphql.String(runtimeCategory)
+	RecordProcessMetric(MetricInput{
+		Name:     graphql.String(name),
+		Value:    graphql.Float(value),
+		Category: &category,
+		Type:     graphql.String(metricType),
+		Unit:     graphql.String(unit),
+	})
+}
+
+// RecordProcessMetric records a metric describing this process rather than a session, such as
+// one gathered from another metrics library. It is recorded without a session, even outside of
+// backend-only mode, grouped by hostname and tagged with the hostname and service name.
+func RecordProcessMetric(metric MetricInput) {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	if state == stopped {
+		stats.drop(DropReasonWorkerStopped)
+		return
+	}
+	if metric.Category == nil {
+		category := graphql.String(metricCategory)
+		metric.Category = &category
+	}
+	if metric.Group == nil {
+		group := graphql.String(hostname)
+		metric.Group = &group
+	}
+	if metric.Timestamp.IsZero() {
+		metric.Timestamp = time.Now().UTC()
+	}
+	metric.ServiceName = graphql.String(serviceName)
+	metric.Environment = graphql.String(environment)
+	metric.Hostname = graphql.String(hostname)
+	metric.SessionAbsent = true
+	metric.Tags = append(append([]MetricTag(nil), metric.Tags...), MetricTag{Name: "hostname", Value: graphql.String(hostname)})
+	if serviceName != "" {
+		metric.Tags = append(metric.Tags, MetricTag{Name: "service", Value: graphql.String(serviceName)})
+	}
+	enqueueMetric(metric)
+}
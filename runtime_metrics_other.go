@@ -0,0 +1,6 @@
+//go:build !unix
+
+package highlight
+
+// collectProcess is a no-op where getrusage is unavailable
+func (c *runtimeCollector) collectProcess() {}
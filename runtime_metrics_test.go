@@ -0,0 +1,77 @@
+package highlight
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRuntimeCollector(t *testing.T) {
+	requester = mockRequester{}
+	SetServiceName("api")
+	defer SetServiceName("")
+	Start()
+	defer Stop()
+
+	collector := newRuntimeCollector()
+	collector.collect()
+	runtime.GC()
+	collector.collect()
+
+	_, metrics := flush()
+	recorded := map[string]*MetricInput{}
+	for _, m := range metrics {
+		recorded[string(m.Name)] = m
+	}
+	for _, name := range []string{"runtime.goroutines", "runtime.heap.inuse", "runtime.gc.cycles", "runtime.gc.pause.p99"} {
+		if _, ok := recorded[name]; !ok {
+			t.Errorf("%s not recorded", name)
+		}
+	}
+	goroutines := recorded["runtime.goroutines"]
+	if goroutines == nil || goroutines.Value < 1 || !bool(goroutines.SessionAbsent) || *goroutines.Category != runtimeCategory {
+		t.Fatalf("runtime metric not recorded without a session: %+v", goroutines)
+	}
+	if len(goroutines.Tags) != 2 || goroutines.Tags[1].Value != "api" {
+		t.Errorf("runtime metric not tagged with the service: %+v", goroutines.Tags)
+	}
+	if cycles := recorded["runtime.gc.cycles"]; cycles != nil && cycles.Value < 1 {
+		t.Errorf("gc cycle not counted: %v", cycles.Value)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	buckets := []float64{0, 1, 2, 3}
+	if v, ok := histogramQuantile([]uint64{1, 2, 7}, buckets, 0.5); !ok || v != 3 {
+		t.Errorf("wrong median [%v != %v]", v, 3)
+	}
+	if v, ok := histogramQuantile([]uint64{1, 2, 7}, buckets, 0.1); !ok || v != 1 {
+		t.Errorf("wrong 10th percentile [%v != %v]", v, 1)
+	}
+	if _, ok := histogramQuantile([]uint64{0, 0, 0}, buckets, 0.5); ok {
+		t.Errorf("quantile of an empty histogram")
+	}
+}
+
+func TestRuntimeMetricsDoNotDelayFlush(t *testing.T) {
+	requester = mockRequester{}
+	SetRuntimeMetricsInterval(time.Millisecond)
+	defer SetRuntimeMetricsInterval(0)
+	SetFlushInterval(20 * time.Millisecond)
+	defer SetFlushInterval(2 * time.Second)
+	before := Stats()
+	Start()
+	defer func() {
+		Stop()
+		// drop the samples taken since the last flush
+		flush()
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for Stats().MetricsExported == before.MetricsExported {
+		if time.Now().After(deadline) {
+			t.Fatalf("no flush while runtime metrics were sampled more often than the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
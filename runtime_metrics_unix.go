@@ -0,0 +1,29 @@
+//go:build unix
+
+package highlight
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// collectProcess records the CPU time and peak resident set size of the process
+func (c *runtimeCollector) collectProcess() {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		logger.Debug("error reading process usage", "error", err)
+		return
+	}
+	c.recordCPU("process.cpu.user", timevalSeconds(usage.Utime))
+	c.recordCPU("process.cpu.system", timevalSeconds(usage.Stime))
+	maxRSS := float64(usage.Maxrss)
+	// Linux reports kilobytes, macOS bytes
+	if runtime.GOOS != "darwin" {
+		maxRSS *= 1024
+	}
+	recordRuntimeMetric("process.memory.max_rss", maxRSS, MetricTypeGauge, "By")
+}
+
+func timevalSeconds(tv syscall.Timeval) float64 {
+	return float64(tv.Sec) + float64(tv.Usec)/1e6
+}
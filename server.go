@@ -0,0 +1,301 @@
+package highlight
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+var (
+	serverMetrics      bool
+	reportServerErrors bool
+	unmatchedRouteName = "unmatched"
+
+	inflightMu sync.Mutex
+	inflight   = map[string]int64{}
+)
+
+// SetServerMetrics makes the router middlewares record the latency, status code, request
+// and response sizes and in-flight count of every request they handle. Requests without a
+// session are recorded like RecordProcessMetric, unless backend-only mode is enabled.
+func SetServerMetrics(enabled bool) {
+	serverMetrics = enabled
+}
+
+// SetReportServerErrors makes the router middlewares report 5xx responses with ConsumeError
+func SetReportServerErrors(enabled bool) {
+	reportServerErrors = enabled
+}
+
+// SetUnmatchedRouteName sets the route that requests matching no route are recorded under.
+// The default is "unmatched".
+func SetUnmatchedRouteName(name string) {
+	unmatchedRouteName = name
+}
+
+// ServerRequest records the metrics of a request handled by a router middleware.
+// Metrics are named http.server.<method> <route>, with the suffixes .duration,
+// .statusCode, .requestBytes, .responseBytes and .inflight. .statusCode counts
+// responses with a status_code tag; .inflight is a gauge recorded as requests start and end,
+// for requests whose route is known when they start.
+type ServerRequest struct {
+	ctx          context.Context
+	method       string
+	route        string
+	name         string
+	inflightName string // empty when the route was unknown at start
+	start        time.Time
+	contentSize  int64
+	requestBytes *countingBody
+	panicked     bool
+}
+
+// StartServerRequest starts recording r, which matched the route template route, counting the
+// bytes read from its body. An empty route records the request under the unmatched route
+// (see SetUnmatchedRouteName) so raw paths never end up in metric names, without counting it
+// in flight. It returns nil when none of SetServerMetrics, SetReportServerErrors and
+// SetRequestCapture is enabled; End is a no-op on a nil ServerRequest.
+func StartServerRequest(ctx context.Context, r *http.Request, route string) *ServerRequest {
+	if !serverMetrics && !reportServerErrors && captureSettings == nil {
+		return nil
+	}
+	known := route != ""
+	if !known {
+		route = unmatchedRouteName
+	}
+	s := &ServerRequest{
+		ctx:         ctx,
+		method:      r.Method,
+		route:       route,
+		name:        fmt.Sprintf("http.server.%s %s", r.Method, route),
+		start:       time.Now(),
+		contentSize: r.ContentLength,
+	}
+	if known {
+		s.inflightName = s.name
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		s.requestBytes = &countingBody{ReadCloser: r.Body}
+		r.Body = s.requestBytes
+	}
+	if serverMetrics && s.inflightName != "" {
+		inflightMu.Lock()
+		inflight[s.inflightName]++
+		current := inflight[s.inflightName]
+		inflightMu.Unlock()
+		s.recordAs(s.inflightName+".inflight", float64(current), MetricTypeGauge, "")
+	}
+	return s
+}
+
+// End records the outcome of the request
+func (s *ServerRequest) End(status int, responseBytes int64) {
+	if s == nil {
+		return
+	}
+	if serverMetrics && s.inflightName != "" {
+		inflightMu.Lock()
+		inflight[s.inflightName]--
+		current := inflight[s.inflightName]
+		if current <= 0 {
+			delete(inflight, s.inflightName)
+		}
+		inflightMu.Unlock()
+		s.recordAs(s.inflightName+".inflight", float64(current), MetricTypeGauge, "")
+	}
+	if serverMetrics {
+
+		requestBytes := s.contentSize
+		if requestBytes < 0 {
+			requestBytes = 0
+			if s.requestBytes != nil {
+				requestBytes = s.requestBytes.n
+			}
+		}
+		s.record(".duration", time.Since(s.start).Seconds(), MetricTypeTimer, "s")
+		s.record(".statusCode", 1, MetricTypeCounter, "", MetricTag{Name: "status_code", Value: graphql.String(fmt.Sprint(status))})
+		s.record(".requestBytes", float64(requestBytes), MetricTypeHistogram, "By")
+		s.record(".responseBytes", float64(responseBytes), MetricTypeHistogram, "By")
+	}
+	if reportServerErrors && status >= http.StatusInternalServerError && !s.panicked {
+		ConsumeError(s.ctx, fmt.Errorf("%s %s returned %d %s", s.method, s.route, status, http.StatusText(status)),
+			"source:highlight.ServerRequest", fmt.Sprintf("status_code:%d", status))
+	}
+}
+
+// SetRoute renames the request after route, for routers that only resolve the route while
+// the handler chain runs, and makes it the source of the 5xx error. The in-flight gauge is
+// only recorded under a route passed to StartServerRequest, so such requests are not counted.
+func (s *ServerRequest) SetRoute(route string) {
+	if s == nil || route == "" {
+		return
+	}
+	s.ctx = WithRoute(s.ctx, route)
+	s.route = route
+	s.name = fmt.Sprintf("http.server.%s %s", s.method, route)
+}
+
+// Panicked marks the request as having panicked. The panic is reported with ConsumePanic,
+// so End records the metrics of the resulting 500 without reporting it a second time.
+func (s *ServerRequest) Panicked() {
+	if s != nil {
+		s.panicked = true
+	}
+}
+
+// record records the metric named after the request with suffix
+func (s *ServerRequest) record(suffix string, value float64, metricType MetricType, unit string, tags ...MetricTag) {
+	s.recordAs(s.name+suffix, value, metricType, unit, tags...)
+}
+
+// recordAs records a metric with the session of the request if it has one,
+// and as a process metric otherwise
+func (s *ServerRequest) recordAs(name string, value float64, metricType MetricType, unit string, tags ...MetricTag) {
+	metric := MetricInput{
+		Name:  graphql.String(name),
+		Value: graphql.Float(value),
+		Type:  graphql.String(metricType),
+		Unit:  graphql.String(unit),
+		Tags:  tags,
+	}
+	if _, _, err := validateRequest(s.ctx); err != nil {
+		RecordProcessMetric(metric)
+		return
+	}
+	recordMetric(s.ctx, metric)
+}
+
+// countingBody counts the bytes read from a request body
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ResponseWriter wraps an http.ResponseWriter to capture the status code and the number of
+// bytes written. It forwards Flush, Hijack, Push and ReadFrom when the wrapped writer supports them.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	capture *requestCapture
+}
+
+// WrapResponseWriter wraps w in a ResponseWriter
+func WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// WrapResponseWriter wraps w in a ResponseWriter that also feeds the response into the
+// request's capture buffer when SetRequestCapture is enabled
+func (s *ServerRequest) WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	rw := WrapResponseWriter(w)
+	if s != nil {
+		rw.capture = captureFromContext(s.ctx)
+	}
+	return rw
+}
+
+// Status returns the status code written, or 200 if the handler never called WriteHeader
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written reports whether the status code has been written
+func (w *ResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+// BytesWritten returns the number of body bytes written
+func (w *ResponseWriter) BytesWritten() int64 {
+	return w.bytes
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		if w.capture != nil {
+			w.capture.writeResponseHeader(status, w.Header())
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	if w.capture != nil && n > 0 {
+		w.capture.writeResponseBody(b[:n])
+	}
+	return n, err
+}
+
+// Flush implements http.Flusher
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w.ResponseWriter)
+	}
+	return h.Hijack()
+}
+
+// Push implements http.Pusher
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// ReadFrom implements io.ReaderFrom, so the wrapped writer can still send files with sendfile.
+// Captured responses are copied through Write instead.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok || w.capture != nil {
+		return io.Copy(writerOnly{w}, r)
+	}
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := rf.ReadFrom(r)
+	w.bytes += n
+	return n, err
+}
+
+// writerOnly hides the ReadFrom method of a ResponseWriter from io.Copy
+type writerOnly struct {
+	io.Writer
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
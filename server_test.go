@@ -0,0 +1,163 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerRequest(t *testing.T) {
+	requester = mockRequester{}
+	SetServerMetrics(true)
+	SetReportServerErrors(true)
+	defer SetServerMetrics(false)
+	defer SetReportServerErrors(false)
+	Start()
+	defer Stop()
+
+	r := httptest.NewRequest("POST", "/charge", strings.NewReader("amount=42"))
+	ctx := WithSession(context.Background(), "session", "request")
+	req := StartServerRequest(ctx, r, "/charge")
+	w := WrapResponseWriter(httptest.NewRecorder())
+	http.Error(w, "card declined", http.StatusBadGateway)
+	req.End(w.Status(), w.BytesWritten())
+
+	errs, metrics := flush()
+	if len(errs) != 1 || errs[0].Event != "POST /charge returned 502 Bad Gateway" {
+		t.Errorf("5xx response not reported: %+v", errs)
+	}
+	values := map[string]float64{}
+	var inflight []float64
+	for _, m := range metrics {
+		values[string(m.Name)] = float64(m.Value)
+		if m.SessionSecureID != "session" {
+			t.Errorf("%s not recorded with the session", m.Name)
+		}
+		if m.Name == "http.server.POST /charge.inflight" {
+			inflight = append(inflight, float64(m.Value))
+		}
+		if m.Name == "http.server.POST /charge.statusCode" && (m.Type != "counter" || len(m.Tags) != 1 || m.Tags[0].Name != "status_code" || m.Tags[0].Value != "502") {
+			t.Errorf("status code not counted with a status_code tag: %+v", m)
+		}
+	}
+	if len(inflight) != 2 || inflight[0] != 1 || inflight[1] != 0 {
+		t.Errorf("in-flight count not recorded at start and end: %v", inflight)
+	}
+	expected := map[string]float64{
+		"http.server.POST /charge.statusCode":    1,
+		"http.server.POST /charge.requestBytes":  9,
+		"http.server.POST /charge.responseBytes": float64(len("card declined\n")),
+	}
+	for name, value := range expected {
+		if v, ok := values[name]; !ok || v != value {
+			t.Errorf("wrong value for %s [%v != %v]", name, v, value)
+		}
+	}
+	if _, ok := values["http.server.POST /charge.duration"]; !ok {
+		t.Errorf("duration not recorded")
+	}
+}
+
+func TestServerRequestWithoutSession(t *testing.T) {
+	requester = mockRequester{}
+	SetServerMetrics(true)
+	defer SetServerMetrics(false)
+	Start()
+	defer Stop()
+
+	r := httptest.NewRequest("GET", "/health", nil)
+	StartServerRequest(context.Background(), r, "/health").End(http.StatusOK, 0)
+	_, metrics := flush()
+	if len(metrics) != 6 {
+		t.Fatalf("flush returned the wrong number of metrics [%v != %v]", len(metrics), 6)
+	}
+	if !metrics[0].SessionAbsent {
+		t.Errorf("metric without a session not recorded as a process metric: %+v", metrics[0])
+	}
+}
+
+func TestServerRequestDisabled(t *testing.T) {
+	if req := StartServerRequest(context.Background(), httptest.NewRequest("GET", "/", nil), "/"); req != nil {
+		t.Errorf("request recorded with server metrics disabled")
+	}
+}
+
+func TestServerRequestRoute(t *testing.T) {
+	requester = mockRequester{}
+	SetServerMetrics(true)
+	SetUnmatchedRouteName("other")
+	defer SetServerMetrics(false)
+	defer SetUnmatchedRouteName("unmatched")
+	Start()
+	defer Stop()
+
+	ctx := WithRoute(WithSession(context.Background(), "session", "request"), "/users/{id}")
+	StartServerRequest(ctx, httptest.NewRequest("GET", "/users/123", nil), "/users/{id}").End(http.StatusOK, 0)
+	StartServerRequest(ctx, httptest.NewRequest("GET", "/nowhere", nil), "").End(http.StatusNotFound, 0)
+	ConsumeError(ctx, fmt.Errorf("user not found"))
+
+	errs, metrics := flush()
+	if len(errs) != 1 || errs[0].Source != "/users/{id}" {
+		t.Errorf("error source is not the route template: %+v", errs)
+	}
+	names := map[string]bool{}
+	for _, m := range metrics {
+		names[string(m.Name)] = true
+	}
+	if !names["http.server.GET /users/{id}.duration"] || !names["http.server.GET other.duration"] {
+		t.Errorf("metrics not named after the route: %v", names)
+	}
+	if names["http.server.GET other.inflight"] {
+		t.Errorf("request without a route counted in flight")
+	}
+}
+
+// readerFromRecorder is a ResponseRecorder supporting io.ReaderFrom and http.Pusher
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+	pushed   string
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom = true
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func (r *readerFromRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushed = target
+	return nil
+}
+
+func TestResponseWriterForwards(t *testing.T) {
+	rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := WrapResponseWriter(rec)
+	if n, err := w.ReadFrom(strings.NewReader("hello")); err != nil || n != 5 || !rec.readFrom {
+		t.Errorf("ReadFrom not forwarded: %v %v %v", n, err, rec.readFrom)
+	}
+	if w.Status() != http.StatusOK || w.BytesWritten() != 5 || rec.Body.String() != "hello" {
+		t.Errorf("ReadFrom not recorded: %v %v %q", w.Status(), w.BytesWritten(), rec.Body.String())
+	}
+	if err := w.Push("/app.js", nil); err != nil || rec.pushed != "/app.js" {
+		t.Errorf("Push not forwarded: %v %q", err, rec.pushed)
+	}
+	if err := WrapResponseWriter(httptest.NewRecorder()).Push("/app.js", nil); err != http.ErrNotSupported {
+		t.Errorf("Push on a writer without http.Pusher [%v != %v]", err, http.ErrNotSupported)
+	}
+
+	SetRequestCapture(&CaptureOptions{})
+	defer SetRequestCapture(nil)
+	r := httptest.NewRequest("GET", "/", nil)
+	ctx := InterceptRequest(r)
+	s := &ServerRequest{ctx: ctx}
+	rec = &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w = s.WrapResponseWriter(rec)
+	_, _ = w.ReadFrom(strings.NewReader("hello"))
+	if rec.readFrom || captureFromContext(ctx).snapshot().ResponseBody != "hello" {
+		t.Errorf("captured response not copied through Write")
+	}
+}
@@ -0,0 +1,174 @@
+//go:build go1.21
+
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// SlogHandlerOptions configures NewSlogHandler
+type SlogHandlerOptions struct {
+	// Level is the minimum level of records reported with ConsumeError. Defaults to slog.LevelError.
+	Level slog.Leveler
+	// ErrorKey is the attribute holding the error value of a record. Defaults to "err".
+	ErrorKey string
+}
+
+// SlogHandler is a slog.Handler that forwards records to another handler, adds the highlight
+// session and request IDs from the context to every record, and reports records at or above
+// a configurable level with ConsumeError.
+type SlogHandler struct {
+	next     slog.Handler
+	level    slog.Leveler
+	errorKey string
+	// attrs and groups added with WithAttrs and WithGroup, kept to tag reported errors
+	attrs  []slog.Attr
+	groups []string
+	// internal is set once the SDK's own attribute, sdk=highlight-go, has been added.
+	// The SDK's log lines are never reported, since ConsumeError itself logs through
+	// the SDK logger, which may share this handler.
+	internal bool
+}
+
+// NewSlogHandler wraps next. Records are reported with their attributes as tags, using the
+// error in the ErrorKey attribute if present and the record message otherwise:
+//
+//	logger := slog.New(highlight.NewSlogHandler(slog.NewJSONHandler(os.Stdout, nil), nil))
+//	logger.ErrorContext(ctx, "charge failed", "err", err, "amount", 42)
+//
+// opts may be nil.
+func NewSlogHandler(next slog.Handler, opts *SlogHandlerOptions) *SlogHandler {
+	h := &SlogHandler{next: next, level: slog.LevelError, errorKey: "err"}
+	if opts != nil {
+		if opts.Level != nil {
+			h.level = opts.Level
+		}
+		if opts.ErrorKey != "" {
+			h.errorKey = opts.ErrorKey
+		}
+	}
+	return h
+}
+
+// Enabled implements slog.Handler
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() || h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.Level >= h.level.Level() && !h.internal && !isSDKRecord(r) {
+		h.consume(ctx, r)
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	if session, ok := SessionFromContext(ctx); ok {
+		r = r.Clone()
+		r.AddAttrs(
+			slog.String(BaggageSessionSecureID, session.SecureID),
+			slog.String(BaggageRequestID, session.RequestID),
+		)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), qualifyAttrs(h.groups, attrs)...)
+	for _, a := range attrs {
+		if isSDKAttr(a) {
+			clone.internal = true
+		}
+	}
+	return &clone
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.groups = append(append([]string{}, h.groups...), name)
+	return &clone
+}
+
+// isSDKRecord reports whether r was logged by the SDK itself
+func isSDKRecord(r slog.Record) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = isSDKAttr(a)
+		return !found
+	})
+	return found
+}
+
+func isSDKAttr(a slog.Attr) bool {
+	return a.Key == sdkAttrKey && a.Value.Resolve().String() == sdkAttrValue
+}
+
+// consume reports r with ConsumeError
+func (h *SlogHandler) consume(ctx context.Context, r slog.Record) {
+	var errorInput interface{} = r.Message
+	tags := make([]string, 0, len(h.attrs)+r.NumAttrs()+1)
+	for _, a := range h.attrs {
+		tags = appendAttrTags(tags, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == h.errorKey {
+			if err, ok := a.Value.Resolve().Any().(error); ok {
+				errorInput = err
+				tags = append(tags, "message:"+r.Message)
+				return true
+			}
+		}
+		tags = appendAttrTags(tags, strings.Join(h.groups, "."), a)
+		return true
+	})
+	tags = append(tags, "level:"+r.Level.String())
+	ConsumeError(ctx, errorInput, tags...)
+}
+
+// qualifyAttrs nests attrs under the open groups
+func qualifyAttrs(groups []string, attrs []slog.Attr) []slog.Attr {
+	if len(groups) == 0 {
+		return attrs
+	}
+	qualified := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		qualified = append(qualified, slog.Attr{Key: strings.Join(groups, ".") + "." + a.Key, Value: a.Value})
+	}
+	return qualified
+}
+
+// appendAttrTags flattens a into "key:value" tags, joining group keys with dots
+func appendAttrTags(tags []string, prefix string, a slog.Attr) []string {
+	v := a.Value.Resolve()
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if v.Kind() == slog.KindGroup {
+		if a.Key == "" {
+			key = prefix
+		}
+		for _, child := range v.Group() {
+			tags = appendAttrTags(tags, key, child)
+		}
+		return tags
+	}
+	if a.Key == "" {
+		return tags
+	}
+	return append(tags, fmt.Sprintf("%s:%v", key, v.Any()))
+}
@@ -0,0 +1,84 @@
+//go:build go1.21
+
+package highlight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	requester = mockRequester{}
+	var buf bytes.Buffer
+	logger := slog.New(NewSlogHandler(slog.NewJSONHandler(&buf, nil), nil))
+	ctx := WithSession(context.Background(), "session", "request")
+
+	Start()
+	logger.InfoContext(ctx, "charging card", "amount", 42)
+	logger.With("user", "alice").WithGroup("charge").ErrorContext(ctx, "charge failed", "err", fmt.Errorf("card declined"), "amount", 42)
+	errs, _ := flush()
+	Stop()
+
+	if len(errs) != 1 {
+		t.Fatalf("flush returned the wrong number of errors [%v != %v]", len(errs), 1)
+	}
+	if errs[0].Event != "card declined" {
+		t.Errorf("event not equal to expected event: %v", errs[0].Event)
+	}
+	var tags []string
+	_ = json.Unmarshal([]byte(*errs[0].Payload), &tags)
+	expected := []string{"user:alice", "message:charge failed", "charge.amount:42", "level:ERROR"}
+	if fmt.Sprint(tags) != fmt.Sprint(expected) {
+		t.Errorf("tags not equal to expected tags: %v != %v", tags, expected)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(bytes.Split(buf.Bytes(), []byte("\n"))[0], &line); err != nil {
+		t.Fatalf("error decoding log line: %v", err)
+	}
+	if line[BaggageSessionSecureID] != "session" || line[BaggageRequestID] != "request" {
+		t.Errorf("log line not tagged with the session: %v", line)
+	}
+}
+
+func TestSlogHandlerErrorKey(t *testing.T) {
+	requester = mockRequester{}
+	logger := slog.New(NewSlogHandler(slog.NewTextHandler(&bytes.Buffer{}, nil), &SlogHandlerOptions{Level: slog.LevelWarn}))
+	ctx := WithSession(context.Background(), "session", "request")
+
+	Start()
+	logger.WarnContext(ctx, "retrying", "err", fmt.Errorf("timeout"))
+	errs, _ := flush()
+	Stop()
+
+	if len(errs) != 1 || errs[0].Event != "timeout" {
+		t.Fatalf("warning not reported with its error: %+v", errs)
+	}
+}
+
+func TestSlogHandlerSkipsSDKLogs(t *testing.T) {
+	requester = mockRequester{}
+	var buf bytes.Buffer
+	handler := NewSlogHandler(slog.NewJSONHandler(&buf, nil), &SlogHandlerOptions{Level: slog.LevelWarn})
+	// the SDK logs through the same handler its error-level records are reported with
+	SetLogger(NewSlogLogger(slog.New(handler)))
+	defer SetLogger(nil)
+
+	Start()
+	// logs "discarding error" at warn level since there is no session
+	ConsumeError(context.Background(), fmt.Errorf("no session"))
+	slog.New(handler).Warn("sdk line", sdkAttrKey, sdkAttrValue)
+	errs, _ := flush()
+	Stop()
+
+	if len(errs) != 0 {
+		t.Errorf("the SDK's own log lines were reported: %+v", errs)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("discarding error")) {
+		t.Errorf("the SDK's log lines were not forwarded: %s", buf.String())
+	}
+}
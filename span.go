@@ -0,0 +1,196 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// spanCategory is the metric category used for finished spans
+const spanCategory = "SPAN"
+
+const spanKey = Highlight + "Span"
+
+// Attribute is a key/value pair describing a span, log line or metric
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Attr builds an Attribute, formatting value with fmt.Sprint
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: fmt.Sprint(value)}
+}
+
+// SpanStatus is the outcome of the operation a span describes
+type SpanStatus string
+
+const (
+	SpanStatusUnset SpanStatus = "unset"
+	SpanStatusOK    SpanStatus = "ok"
+	SpanStatusError SpanStatus = "error"
+)
+
+// Span times a single operation, such as a DB query, within a trace.
+// Spans started from a context carrying another span become its children.
+type Span struct {
+	ctx          context.Context
+	name         string
+	traceID      string
+	spanID       string
+	parentSpanID string
+	start        time.Time
+
+	mu            sync.Mutex
+	attributes    []Attribute
+	status        SpanStatus
+	statusMessage string
+	ended         bool
+}
+
+// StartSpan starts a span named name and returns a context carrying it.
+// Pass the returned context to the work being timed and call End when it is done:
+//
+//	ctx, span := highlight.StartSpan(ctx, "db.query", highlight.Attr("table", "users"))
+//	defer span.End()
+//
+// Errors passed to ConsumeError with the returned context are linked to the span.
+// If ctx carries neither a span nor a W3C trace context, a new trace is started.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	span := &Span{
+		name:       name,
+		spanID:     newSpanID(),
+		start:      time.Now(),
+		attributes: attrs,
+		status:     SpanStatusUnset,
+	}
+	sampled := true
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		span.traceID = tc.TraceID
+		span.parentSpanID = tc.SpanID
+		sampled = tc.Sampled
+	} else {
+		span.traceID = newTraceID()
+	}
+	ctx = WithTraceContext(ctx, TraceContext{TraceID: span.traceID, SpanID: span.spanID, Sampled: sampled})
+	ctx = context.WithValue(ctx, spanKey, span)
+	span.ctx = ctx
+	return ctx, span
+}
+
+// SpanFromContext returns the active span carried by ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// TraceID returns the hex encoded ID of the trace the span belongs to
+func (s *Span) TraceID() string {
+	return s.traceID
+}
+
+// SpanID returns the hex encoded ID of the span
+func (s *Span) SpanID() string {
+	return s.spanID
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attrs...)
+}
+
+// SetStatus sets the outcome of the span, with an optional description
+func (s *Span) SetStatus(status SpanStatus, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+	s.statusMessage = message
+}
+
+// RecordError reports err with ConsumeError, linked to the span, and marks the span as failed
+func (s *Span) RecordError(err error, tags ...string) {
+	s.markError(err.Error())
+	ConsumeError(s.ctx, err, tags...)
+}
+
+// markError flags the span as failed unless a status was already set explicitly
+func (s *Span) markError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == SpanStatusUnset {
+		s.status = SpanStatusError
+		s.statusMessage = message
+	}
+}
+
+// End finishes the span and records it with RecordSpan.
+// Calling End more than once has no effect.
+func (s *Span) End() {
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:          s.name,
+		TraceID:       s.traceID,
+		SpanID:        s.spanID,
+		ParentSpanID:  s.parentSpanID,
+		Start:         s.start,
+		End:           end,
+		Status:        s.status,
+		StatusMessage: s.statusMessage,
+		Attributes:    s.attributes,
+	}
+	s.mu.Unlock()
+	RecordSpan(s.ctx, data)
+}
+
+// SpanData describes a finished span
+type SpanData struct {
+	Name          string
+	TraceID       string
+	SpanID        string
+	ParentSpanID  string
+	Start         time.Time
+	End           time.Time
+	Status        SpanStatus
+	StatusMessage string
+	Attributes    []Attribute
+}
+
+// RecordSpan records a finished span as a SPAN metric whose value is its duration in seconds,
+// timestamped with its start time. Spans timed by StartSpan are recorded when they End;
+// use RecordSpan directly for spans timed elsewhere, e.g. by another tracing library.
+func RecordSpan(ctx context.Context, data SpanData) {
+	tags := make([]MetricTag, 0, len(data.Attributes)+2)
+	for _, a := range data.Attributes {
+		tags = append(tags, MetricTag{Name: graphql.String(a.Key), Value: graphql.String(a.Value)})
+	}
+	status := data.Status
+	if status == "" {
+		status = SpanStatusUnset
+	}
+	tags = append(tags, MetricTag{Name: "status", Value: graphql.String(status)})
+	if data.StatusMessage != "" {
+		tags = append(tags, MetricTag{Name: "status_message", Value: graphql.String(data.StatusMessage)})
+	}
+	category := graphql.String(spanCategory)
+	recordMetric(ctx, MetricInput{
+		Name:         graphql.String(data.Name),
+		Value:        graphql.Float(data.End.Sub(data.Start).Seconds()),
+		Category:     &category,
+		Timestamp:    data.Start.UTC(),
+		TraceID:      graphql.String(data.TraceID),
+		SpanID:       graphql.String(data.SpanID),
+		ParentSpanID: graphql.String(data.ParentSpanID),
+		Tags:         tags,
+	})
+}
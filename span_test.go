@@ -0,0 +1,49 @@
+package highlight
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSpan(t *testing.T) {
+	requester = mockRequester{}
+	ctx := WithSession(context.Background(), "0", "0")
+
+	Start()
+	ctx, parent := StartSpan(ctx, "handler", Attr("route", "/users"))
+	childCtx, child := StartSpan(ctx, "db.query", Attr("rows", 3))
+	ConsumeError(childCtx, fmt.Errorf("query failed"))
+	child.End()
+	child.End()
+	parent.End()
+
+	errs, metrics := flush()
+	if len(errs) != 1 {
+		t.Fatalf("flush returned the wrong number of errors [%v != %v]", len(errs), 1)
+	}
+	if string(errs[0].SpanID) != child.SpanID() || string(errs[0].TraceID) != parent.TraceID() {
+		t.Errorf("error not linked to the span: %+v", errs[0])
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("flush returned the wrong number of metrics [%v != %v]", len(metrics), 2)
+	}
+	childMetric, parentMetric := metrics[0], metrics[1]
+	if childMetric.Name != "db.query" || *childMetric.Category != spanCategory {
+		t.Errorf("unexpected child span metric: %+v", childMetric)
+	}
+	if string(childMetric.ParentSpanID) != parent.SpanID() || childMetric.TraceID != parentMetric.TraceID {
+		t.Errorf("child span not nested under its parent: %+v", childMetric)
+	}
+	if parentMetric.ParentSpanID != "" {
+		t.Errorf("root span has a parent: %+v", parentMetric)
+	}
+	tags := map[string]string{}
+	for _, tag := range childMetric.Tags {
+		tags[string(tag.Name)] = string(tag.Value)
+	}
+	if tags["rows"] != "3" || tags["status"] != string(SpanStatusError) || tags["status_message"] != "query failed" {
+		t.Errorf("unexpected child span tags: %+v", tags)
+	}
+	Stop()
+}
@@ -0,0 +1,152 @@
+package highlight
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DropReason describes why an error, metric or log line never made it into the export queue
+type DropReason string
+
+const (
+	DropReasonChannelFull      DropReason = "channel_full"
+	DropReasonSessionMissing   DropReason = "session_missing"
+	DropReasonRequestIDMissing DropReason = "request_id_missing"
+	DropReasonWorkerStopped    DropReason = "worker_stopped"
+	DropReasonMarshalError     DropReason = "marshal_error"
+	DropReasonSeriesLimit      DropReason = "series_limit"
+)
+
+// StatsSnapshot is a point in time copy of the SDK's self-telemetry counters.
+// It can be used to tell whether data was dropped before reaching the queue,
+// failed on export, or was never recorded in the first place.
+// With SetMetricAggregation, metric values are counted in MetricsAggregated rather than MetricsEnqueued.
+type StatsSnapshot struct {
+	ErrorsEnqueued    uint64                `json:"errors_enqueued"`
+	MetricsEnqueued   uint64                `json:"metrics_enqueued"`
+	LogsEnqueued      uint64                `json:"logs_enqueued"`
+	MetricsAggregated uint64                `json:"metrics_aggregated"`
+	Dropped           map[DropReason]uint64 `json:"dropped"`
+	ErrorsExported    uint64                `json:"errors_exported"`
+	MetricsExported   uint64                `json:"metrics_exported"`
+	LogsExported      uint64                `json:"logs_exported"`
+	ExportFailures    uint64                `json:"export_failures"`
+	ExportRetries     uint64                `json:"export_retries"`
+	QueueDepth        int                   `json:"queue_depth"`
+	LastExport        time.Time             `json:"last_export"`
+	LastError         string                `json:"last_error,omitempty"`
+}
+
+// sdkStats holds the live counters behind Stats
+type sdkStats struct {
+	errorsEnqueued    atomic.Uint64
+	metricsEnqueued   atomic.Uint64
+	logsEnqueued      atomic.Uint64
+	metricsAggregated atomic.Uint64
+	dropped           [len(dropReasonIndexes)]atomic.Uint64
+	errorsExported    atomic.Uint64
+	metricsExported   atomic.Uint64
+	logsExported      atomic.Uint64
+	exportFailures    atomic.Uint64
+	exportRetries     atomic.Uint64
+
+	mu         sync.Mutex
+	lastExport time.Time
+	lastError  string
+}
+
+var dropReasonIndexes = [...]DropReason{
+	DropReasonChannelFull,
+	DropReasonSessionMissing,
+	DropReasonRequestIDMissing,
+	DropReasonWorkerStopped,
+	DropReasonMarshalError,
+	DropReasonSeriesLimit,
+}
+
+var stats sdkStats
+
+func (s *sdkStats) drop(reason DropReason) {
+	for i, r := range dropReasonIndexes {
+		if r == reason {
+			s.dropped[i].Add(1)
+			return
+		}
+	}
+}
+
+func (s *sdkStats) exported(errorCount, metricCount, logCount int) {
+	s.errorsExported.Add(uint64(errorCount))
+	s.metricsExported.Add(uint64(metricCount))
+	s.logsExported.Add(uint64(logCount))
+	s.mu.Lock()
+	s.lastExport = time.Now().UTC()
+	s.mu.Unlock()
+}
+
+func (s *sdkStats) failed(err error) {
+	s.exportFailures.Add(1)
+	s.mu.Lock()
+	s.lastError = err.Error()
+	s.mu.Unlock()
+}
+
+// dropReasonForValidation maps a validateRequest error onto a DropReason
+func dropReasonForValidation(err error) DropReason {
+	switch err.Error() {
+	case consumeErrorSessionIDMissing:
+		return DropReasonSessionMissing
+	case consumeErrorRequestIDMissing:
+		return DropReasonRequestIDMissing
+	default:
+		return DropReasonWorkerStopped
+	}
+}
+
+// Stats returns a snapshot of the SDK's internal counters.
+func Stats() StatsSnapshot {
+	snapshot := StatsSnapshot{
+		ErrorsEnqueued:    stats.errorsEnqueued.Load(),
+		MetricsEnqueued:   stats.metricsEnqueued.Load(),
+		LogsEnqueued:      stats.logsEnqueued.Load(),
+		MetricsAggregated: stats.metricsAggregated.Load(),
+		Dropped:           make(map[DropReason]uint64, len(dropReasonIndexes)),
+		ErrorsExported:    stats.errorsExported.Load(),
+		MetricsExported:   stats.metricsExported.Load(),
+		LogsExported:      stats.logsExported.Load(),
+		ExportFailures:    stats.exportFailures.Load(),
+		ExportRetries:     stats.exportRetries.Load(),
+		QueueDepth:        len(errorChan) + len(metricChan) + len(logChan),
+	}
+	for i, r := range dropReasonIndexes {
+		snapshot.Dropped[r] = stats.dropped[i].Load()
+	}
+	stats.mu.Lock()
+	snapshot.LastExport = stats.lastExport
+	snapshot.LastError = stats.lastError
+	stats.mu.Unlock()
+	return snapshot
+}
+
+// PublishExpvar exposes Stats under the given expvar name, e.g. on /debug/vars.
+// Like expvar.Publish, it panics if the name is already registered.
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return Stats()
+	}))
+}
+
+// StatsHandler returns an http.Handler that serves Stats as JSON,
+// suitable for mounting on a debug port.
+func StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(Stats()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package highlight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+// failingRequester fails the first `failures` triggers before succeeding
+type failingRequester struct {
+	failures *int
+}
+
+func (f failingRequester) trigger(errorsInput []*BackendErrorObjectInput, metricsInput []*MetricInput) error {
+	if *f.failures > 0 {
+		*f.failures--
+		return fmt.Errorf("export failed")
+	}
+	return nil
+}
+
+func (f failingRequester) triggerLogs(logsInput []*LogInput) error {
+	return f.trigger(nil, nil)
+}
+
+func TestStats(t *testing.T) {
+	failures := 1
+	requester = failingRequester{failures: &failures}
+	defer func() { requester = mockRequester{} }()
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, ContextKeys.SessionSecureID, "0")
+	ctx = context.WithValue(ctx, ContextKeys.RequestID, "0")
+
+	Start()
+	before := Stats()
+	ConsumeError(ctx, fmt.Errorf("error here"))
+	ConsumeError(context.Background(), fmt.Errorf("error here"))
+	RecordMetric(ctx, "myMetric", 1)
+	after := Stats()
+	if after.ErrorsEnqueued-before.ErrorsEnqueued != 1 {
+		t.Errorf("wrong number of enqueued errors [%v != %v]", after.ErrorsEnqueued-before.ErrorsEnqueued, 1)
+	}
+	if after.MetricsEnqueued-before.MetricsEnqueued != 1 {
+		t.Errorf("wrong number of enqueued metrics [%v != %v]", after.MetricsEnqueued-before.MetricsEnqueued, 1)
+	}
+	if after.Dropped[DropReasonSessionMissing]-before.Dropped[DropReasonSessionMissing] != 1 {
+		t.Errorf("session missing drop was not counted")
+	}
+	if after.QueueDepth != 2 {
+		t.Errorf("wrong queue depth [%v != %v]", after.QueueDepth, 2)
+	}
+
+	export(flush())
+	exported := Stats()
+	if exported.ExportFailures-after.ExportFailures != 1 || exported.ExportRetries-after.ExportRetries != 1 {
+		t.Errorf("expected one failure and one retry, got %v and %v", exported.ExportFailures-after.ExportFailures, exported.ExportRetries-after.ExportRetries)
+	}
+	if exported.ErrorsExported-after.ErrorsExported != 1 || exported.MetricsExported-after.MetricsExported != 1 {
+		t.Errorf("exported counters not updated")
+	}
+	if exported.LastExport.IsZero() || exported.LastError != "export failed" {
+		t.Errorf("last export or last error not recorded: %v %q", exported.LastExport, exported.LastError)
+	}
+
+	rec := httptest.NewRecorder()
+	StatsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var served StatsSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&served); err != nil {
+		t.Errorf("error decoding stats handler response: %v", err)
+	}
+	Stop()
+}
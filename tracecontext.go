@@ -0,0 +1,167 @@
+package highlight
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// W3C Trace Context (https://www.w3.org/TR/trace-context/) and Baggage
+// (https://www.w3.org/TR/baggage/) headers
+const (
+	traceparentHeader = "traceparent"
+	baggageHeader     = "baggage"
+)
+
+// baggage entries used to carry the highlight IDs through a service mesh
+const (
+	BaggageSessionSecureID = "highlight.session_secure_id"
+	BaggageRequestID       = "highlight.request_id"
+)
+
+const traceContextKey = Highlight + "TraceContext"
+
+// TraceContext identifies the W3C trace and span that a request belongs to.
+// IDs are lowercase hex strings, 32 characters for the trace ID and 16 for the span ID.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// WithTraceContext returns a copy of ctx carrying tc. Errors and metrics recorded
+// with the returned context are tagged with its trace and span IDs.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey, tc)
+}
+
+// TraceContextFromContext returns the trace context carried by ctx, if any.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey).(TraceContext)
+	return tc, ok
+}
+
+// parseTraceparent parses a version 00 compatible traceparent header value,
+// e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(value string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return TraceContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// future versions may append fields, but version 00 has exactly four
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return TraceContext{}, false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return TraceContext{}, false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return TraceContext{}, false
+	}
+	if !isLowerHex(flags, 2) {
+		return TraceContext{}, false
+	}
+	flagBytes, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Sampled: flagBytes[0]&1 == 1}, true
+}
+
+func formatTraceparent(tc TraceContext) string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + flags
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBaggage returns the members of a baggage header value, ignoring properties
+// and malformed members.
+func parseBaggage(value string) map[string]string {
+	members := map[string]string{}
+	for _, member := range strings.Split(value, ",") {
+		member, _, _ = strings.Cut(member, ";")
+		key, val, found := strings.Cut(member, "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		val, err := url.PathUnescape(strings.TrimSpace(val))
+		if key == "" || err != nil {
+			continue
+		}
+		members[key] = val
+	}
+	return members
+}
+
+// sessionFromBaggage reads the highlight IDs from the request's baggage header
+func sessionFromBaggage(r *http.Request) (Session, bool) {
+	value := r.Header.Get(baggageHeader)
+	if value == "" {
+		return Session{}, false
+	}
+	members := parseBaggage(value)
+	session := Session{SecureID: members[BaggageSessionSecureID], RequestID: members[BaggageRequestID]}
+	if !validHighlightID(session.SecureID) || !validHighlightID(session.RequestID) {
+		return Session{}, false
+	}
+	return session, true
+}
+
+// injectTraceHeaders writes the highlight baggage entries and, if ctx carries a trace,
+// a traceparent naming the parent span onto the outgoing request's headers.
+// An existing traceparent is left alone since another tracer already owns it.
+func injectTraceHeaders(ctx context.Context, header http.Header) {
+	if session, ok := SessionFromContext(ctx); ok {
+		var members []string
+		if existing := header.Get(baggageHeader); existing != "" {
+			for _, member := range strings.Split(existing, ",") {
+				key, _, _ := strings.Cut(member, "=")
+				key = strings.TrimSpace(key)
+				if key != "" && key != BaggageSessionSecureID && key != BaggageRequestID {
+					members = append(members, strings.TrimSpace(member))
+				}
+			}
+		}
+		members = append(members,
+			BaggageSessionSecureID+"="+url.PathEscape(session.SecureID),
+			BaggageRequestID+"="+url.PathEscape(session.RequestID),
+		)
+		header.Set(baggageHeader, strings.Join(members, ","))
+	}
+	// the parent is the active span, whose ID StartSpan puts in the trace context, or else the
+	// incoming parent span; a span ID made up here would never be reported
+	if tc, ok := TraceContextFromContext(ctx); ok && header.Get(traceparentHeader) == "" {
+		header.Set(traceparentHeader, formatTraceparent(tc))
+	}
+}
+
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package highlight
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := map[string]struct {
+		value string
+		ok    bool
+		tc    TraceContext
+	}{
+		"valid sampled":     {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ok: true, tc: TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true}},
+		"valid not sampled": {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", ok: true, tc: TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}},
+		"future version":    {value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ok: true, tc: TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true}},
+		"invalid version":   {value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"zero trace id":     {value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		"zero span id":      {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		"uppercase":         {value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		"extra field":       {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		"empty":             {value: ""},
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc, ok := parseTraceparent(input.value)
+			if ok != input.ok || (ok && tc != input.tc) {
+				t.Errorf("parseTraceparent(%q) = %+v, %v; expected %+v, %v", input.value, tc, ok, input.tc, input.ok)
+			}
+		})
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	requester = mockRequester{}
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("traceparent", fmt.Sprintf("00-%s-00f067aa0ba902b7-01", traceID))
+	r.Header.Set("baggage", "userId=alice, highlight.session_secure_id=session;prop=1,highlight.request_id=request")
+	ctx := InterceptRequest(r)
+
+	session, ok := SessionFromContext(ctx)
+	if !ok || session.SecureID != "session" || session.RequestID != "request" {
+		t.Fatalf("session not read from baggage: %+v", session)
+	}
+
+	Start()
+	ConsumeError(ctx, fmt.Errorf("error here"))
+	RecordMetric(ctx, "myMetric", 1)
+	errs, metrics := flush()
+	if len(errs) != 1 || len(metrics) != 1 {
+		t.Fatalf("flush returned the wrong number of errors and metrics [%v, %v != 1, 1]", len(errs), len(metrics))
+	}
+	if errs[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || errs[0].SpanID != "00f067aa0ba902b7" {
+		t.Errorf("error not tagged with the trace: %+v", errs[0])
+	}
+	if metrics[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || metrics[0].SpanID != "00f067aa0ba902b7" {
+		t.Errorf("metric not tagged with the trace: %+v", metrics[0])
+	}
+	Stop()
+
+	header := http.Header{}
+	header.Set("baggage", "userId=alice,highlight.request_id=stale")
+	injectTraceHeaders(ctx, header)
+	outgoing := parseBaggage(header.Get("baggage"))
+	if outgoing["userId"] != "alice" || outgoing[BaggageSessionSecureID] != "session" || outgoing[BaggageRequestID] != "request" {
+		t.Errorf("baggage not merged: %q", header.Get("baggage"))
+	}
+	tc, ok := parseTraceparent(header.Get("traceparent"))
+	if !ok || tc.TraceID != traceID || tc.SpanID != "00f067aa0ba902b7" || !strings.HasPrefix(header.Get("traceparent"), "00-") {
+		t.Errorf("traceparent not propagated with the incoming parent span: %q", header.Get("traceparent"))
+	}
+
+	spanCtx, span := StartSpan(ctx, "call")
+	defer span.End()
+	header = http.Header{}
+	injectTraceHeaders(spanCtx, header)
+	if tc, _ := parseTraceparent(header.Get("traceparent")); tc.TraceID != traceID || tc.SpanID != span.spanID {
+		t.Errorf("traceparent not propagated with the active span: %q", header.Get("traceparent"))
+	}
+}
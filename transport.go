@@ -0,0 +1,111 @@
+package highlight
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	clientDuration = NewTimer("http.client.duration", nil)
+	clientRequests = NewCounter("http.client.requests", nil)
+)
+
+// Transport is an http.RoundTripper that propagates the highlight session and request IDs
+// from the outgoing request's context to the services listed in PropagationTargets, so errors
+// raised there can be tied back to the user's session. The IDs are sent both in the
+// X-Highlight-Request header and as W3C baggage entries, along with a traceparent if the
+// context carries a trace. Each request is also recorded as a breadcrumb if the context
+// carries a breadcrumb buffer.
+type Transport struct {
+	// Base is the RoundTripper used to make the request. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+	// PropagationTargets are the hosts the IDs and trace headers are sent to, e.g.
+	// "billing.internal" or ".internal.example.com" for every subdomain of internal.example.com.
+	// The session secure ID must not leak to third-party APIs, so nothing is propagated by default.
+	PropagationTargets []string
+	// RecordMetrics records the latency of each request as http.client.duration and counts
+	// requests as http.client.requests, tagged with the host and the status code.
+	RecordMetrics bool
+	// ReportErrors reports transport errors with ConsumeError.
+	ReportErrors bool
+}
+
+// NewTransport wraps base in a Transport. Use it as the Transport of your http.Client:
+//
+//	transport := highlight.NewTransport(http.DefaultTransport)
+//	transport.PropagationTargets = []string{"service-b"}
+//	client := &http.Client{Transport: transport}
+//	req, _ := http.NewRequestWithContext(ctx, "GET", "https://service-b/api", nil)
+//	client.Do(req)
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := req.Context()
+	_, hasTrace := TraceContextFromContext(ctx)
+	session, hasSession := SessionFromContext(ctx)
+	if (hasSession || hasTrace) && t.propagatesTo(req.URL.Hostname()) {
+		// a RoundTripper must not modify the request it was given
+		req = req.Clone(ctx)
+		if hasSession {
+			req.Header.Set(outgoingRequestHeaderName(), session.SecureID+"/"+session.RequestID)
+		}
+		injectTraceHeaders(ctx, req.Header)
+	}
+	breadcrumbs := breadcrumbsFromContext(ctx)
+	if !t.RecordMetrics && !t.ReportErrors && breadcrumbs == nil {
+		return base.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	breadcrumbs.add(httpBreadcrumb(req, resp, err, time.Since(start)))
+	// without a session (and outside of backend-only mode) there is nowhere to record to
+	if _, _, verr := validateRequest(ctx); verr != nil {
+		return resp, err
+	}
+	if t.RecordMetrics {
+		host := Attr("host", req.URL.Host)
+		clientDuration.Record(ctx, time.Since(start), host)
+		status := "error"
+		if resp != nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		clientRequests.Add(ctx, 1, host, Attr("status_code", status))
+	}
+	if err != nil && t.ReportErrors {
+		ConsumeError(ctx, fmt.Errorf("%s %s: %w", req.Method, scrubURL(req.URL), err), "source:highlight.Transport")
+	}
+	return resp, err
+}
+
+// propagatesTo reports whether host is one of the PropagationTargets
+func (t *Transport) propagatesTo(host string) bool {
+	for _, target := range t.PropagationTargets {
+		if strings.HasPrefix(target, ".") {
+			if strings.HasSuffix(strings.ToLower(host), strings.ToLower(target)) {
+				return true
+			}
+		} else if strings.EqualFold(host, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// outgoingRequestHeaderName is the header used to hand the IDs to the next service
+func outgoingRequestHeaderName() string {
+	if len(requestHeaderNames) > 0 {
+		return requestHeaderNames[0]
+	}
+	return "X-Highlight-Request"
+}